@@ -37,13 +37,18 @@ func main() {
 	config := loadConfig()
 
 	// Initialize Chrome converter
-	converter, err := converters.NewChromeConverter(config.MaxWorkers)
+	converter, err := converters.NewChromeConverter(converters.ChromeConfig{
+		MaxWorkers:  config.MaxWorkers,
+		MaxRenders:  config.ChromeMaxRenders,
+		MaxMemoryMB: config.ChromeMaxMemoryMB,
+		RemoteURLs:  config.ChromeRemoteURLs,
+	})
 	if err != nil {
 		logger.Error("Failed to initialize Chrome converter", "error", err)
 		os.Exit(1)
 	}
 	defer converter.Close()
-	logger.Info("Chrome converter initialized", "workers", config.MaxWorkers)
+	logger.Info("Chrome converter initialized", "workers", config.MaxWorkers, "remote_endpoints", len(config.ChromeRemoteURLs))
 
 	// Initialize PDF processor (for security, watermarks, etc.)
 	processor, err := converters.NewPDFProcessor()
@@ -52,6 +57,30 @@ func main() {
 		processor = nil
 	} else {
 		defer processor.Close()
+		if config.PDFAICCProfile != "" {
+			processor.SetICCProfile(config.PDFAICCProfile)
+		}
+		if config.PDFXICCProfile != "" {
+			processor.SetCMYKProfile(config.PDFXICCProfile)
+		}
+		if config.SignP12Path != "" {
+			signer, err := converters.LoadSigner(config.SignP12Path, config.SignP12Password)
+			if err != nil {
+				logger.Error("Failed to load signing certificate", "path", config.SignP12Path, "error", err)
+			} else {
+				processor.SetSigner(signer)
+				logger.Info("Signing certificate loaded", "subject", signer.Name())
+			}
+		}
+		processor.SetTimestampURL(config.SignTSAURL)
+		if config.SignTrustStore != "" {
+			pool, err := converters.LoadTrustStore(config.SignTrustStore)
+			if err != nil {
+				logger.Error("Failed to load signature trust store", "path", config.SignTrustStore, "error", err)
+			} else {
+				processor.SetTrustStore(pool)
+			}
+		}
 		logger.Info("PDF processor initialized")
 	}
 
@@ -161,26 +190,44 @@ func main() {
 }
 
 type Config struct {
-	Address      string
-	APIKey       string
-	MaxWorkers   int
-	MaxBodySize  int64
-	RateLimit    int
-	CORSOrigins  []string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Address           string
+	APIKey            string
+	MaxWorkers        int
+	MaxBodySize       int64
+	RateLimit         int
+	CORSOrigins       []string
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	ChromeMaxRenders  int
+	ChromeMaxMemoryMB int
+	ChromeRemoteURLs  []string
+	PDFAICCProfile    string
+	PDFXICCProfile    string
+	SignP12Path       string
+	SignP12Password   string
+	SignTSAURL        string
+	SignTrustStore    string
 }
 
 func loadConfig() Config {
 	return Config{
-		Address:      getEnv("ADDRESS", ":8080"),
-		APIKey:       os.Getenv("API_KEY"),
-		MaxWorkers:   getEnvInt("MAX_WORKERS", 4),
-		MaxBodySize:  getEnvInt64("MAX_BODY_SIZE", 500*1024*1024), // 500MB default
-		RateLimit:    getEnvInt("RATE_LIMIT", 0),                  // 0 = disabled
-		CORSOrigins:  getEnvSlice("CORS_ORIGINS", nil),
-		ReadTimeout:  time.Duration(getEnvInt("READ_TIMEOUT", 300)) * time.Second,
-		WriteTimeout: time.Duration(getEnvInt("WRITE_TIMEOUT", 300)) * time.Second,
+		Address:           getEnv("ADDRESS", ":8080"),
+		APIKey:            os.Getenv("API_KEY"),
+		MaxWorkers:        getEnvInt("MAX_WORKERS", 4),
+		MaxBodySize:       getEnvInt64("MAX_BODY_SIZE", 500*1024*1024), // 500MB default
+		RateLimit:         getEnvInt("RATE_LIMIT", 0),                  // 0 = disabled
+		CORSOrigins:       getEnvSlice("CORS_ORIGINS", nil),
+		ReadTimeout:       time.Duration(getEnvInt("READ_TIMEOUT", 300)) * time.Second,
+		WriteTimeout:      time.Duration(getEnvInt("WRITE_TIMEOUT", 300)) * time.Second,
+		ChromeMaxRenders:  getEnvInt("CHROME_MAX_RENDERS", 200),    // 0 = never recycle
+		ChromeMaxMemoryMB: getEnvInt("CHROME_MAX_MEMORY_MB", 1024), // 0 = no limit
+		ChromeRemoteURLs:  getEnvSlice("CHROME_WS_URL", nil),       // empty = launch local Chrome
+		PDFAICCProfile:    os.Getenv("PDFA_ICC_PROFILE"),           // empty = system sRGB profile
+		PDFXICCProfile:    os.Getenv("PDFX_ICC_PROFILE"),           // empty = Ghostscript default CMYK profile
+		SignP12Path:       os.Getenv("SIGN_P12_PATH"),              // empty = signing disabled
+		SignP12Password:   os.Getenv("SIGN_P12_PASSWORD"),
+		SignTSAURL:        os.Getenv("SIGN_TSA_URL"),     // empty = no timestamp unless requested
+		SignTrustStore:    os.Getenv("SIGN_TRUST_STORE"), // empty = system roots
 	}
 }
 
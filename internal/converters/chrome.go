@@ -3,6 +3,7 @@ package converters
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"pdf-forge/internal/models"
@@ -12,41 +13,71 @@ import (
 )
 
 type ChromeConverter struct {
-	allocCtx    context.Context
-	cancelAlloc context.CancelFunc
-	semaphore   chan struct{}
+	pool      *browserPool
+	remote    *remoteEndpoints
+	semaphore chan struct{}
 }
 
-func NewChromeConverter(maxWorkers int) (*ChromeConverter, error) {
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-setuid-sandbox", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-	)
-
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-
-	// Warm up
-	ctx, c := chromedp.NewContext(allocCtx)
-	chromedp.Run(ctx)
-	c()
-
-	return &ChromeConverter{
-		allocCtx:    allocCtx,
-		cancelAlloc: cancel,
-		semaphore:   make(chan struct{}, maxWorkers),
-	}, nil
+func NewChromeConverter(cfg ChromeConfig) (*ChromeConverter, error) {
+	if cfg.MaxWorkers <= 0 {
+		cfg.MaxWorkers = 1
+	}
+
+	c := &ChromeConverter{
+		semaphore: make(chan struct{}, cfg.MaxWorkers),
+	}
+
+	var newAllocator func() (context.Context, context.CancelFunc, error)
+	if len(cfg.RemoteURLs) > 0 {
+		c.remote = newRemoteEndpoints(cfg.RemoteURLs)
+		newAllocator = c.remote.allocate
+	} else {
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.Flag("headless", true),
+			chromedp.Flag("disable-gpu", true),
+			chromedp.Flag("no-sandbox", true),
+			chromedp.Flag("disable-setuid-sandbox", true),
+			chromedp.Flag("disable-dev-shm-usage", true),
+		)
+		newAllocator = func() (context.Context, context.CancelFunc, error) {
+			ctx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+			return ctx, cancel, nil
+		}
+	}
+
+	// Warm up one browser per worker
+	pool, err := newBrowserPool(cfg, newAllocator)
+	if err != nil {
+		if c.remote != nil {
+			c.remote.close()
+		}
+		return nil, err
+	}
+	c.pool = pool
+
+	return c, nil
 }
 
 func (c *ChromeConverter) Close() {
-	c.cancelAlloc()
+	c.pool.close()
+	if c.remote != nil {
+		c.remote.close()
+	}
 }
 
 // GetWorkerStatus is required by Health handler
 func (c *ChromeConverter) GetWorkerStatus() models.WorkerStatus {
-	return models.WorkerStatus{Max: cap(c.semaphore), InUse: len(c.semaphore)}
+	inUse := len(c.semaphore)
+	pool := c.pool.status()
+	if c.remote != nil {
+		pool.Endpoints = c.remote.status()
+	}
+	return models.WorkerStatus{
+		Max:       cap(c.semaphore),
+		Available: cap(c.semaphore) - inUse,
+		InUse:     inUse,
+		Pool:      pool,
+	}
 }
 
 // GetMetrics is required by Health handler
@@ -54,102 +85,215 @@ func (c *ChromeConverter) GetMetrics() models.ConversionMetrics {
 	return models.ConversionMetrics{}
 }
 
-func (c *ChromeConverter) ConvertHTML(ctx context.Context, html string, opts *models.PDFOptions) ([]byte, error) {
+// withTab runs fn on a warm pooled tab, bounded by the semaphore and timeout.
+// Cancelling ctx aborts the render without closing the pooled browser.
+func (c *ChromeConverter) withTab(ctx context.Context, timeout time.Duration, fn func(taskCtx context.Context) error) error {
 	c.semaphore <- struct{}{}
 	defer func() { <-c.semaphore }()
 
-	taskCtx, cancel := chromedp.NewContext(c.allocCtx)
-	defer cancel()
-	taskCtx, cancel = context.WithTimeout(taskCtx, 90*time.Second) // Increased timeout
-	defer cancel()
+	w, err := c.pool.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("no browser available: %w", err)
+	}
+
+	taskCtx, cancel := context.WithTimeout(w.tabCtx, timeout)
+	stop := context.AfterFunc(ctx, cancel)
+
+	err = fn(taskCtx)
 
+	stop()
+	cancel()
+	c.pool.release(w, err)
+	return err
+}
+
+func (c *ChromeConverter) ConvertHTML(ctx context.Context, html string, opts *models.PDFOptions) ([]byte, error) {
 	var buf []byte
+	var headings []outlineHeading
+	err := c.withTab(ctx, 90*time.Second, func(taskCtx context.Context) error {
+		waiter := newReadinessWaiter(taskCtx, opts, 3*time.Second)
 
-	width, height := 8.27, 11.69
-	if opts != nil && opts.PageSize == "Letter" {
-		width, height = 8.5, 11
-	}
-	if opts != nil && opts.Orientation == "landscape" {
-		width, height = height, width
+		return chromedp.Run(taskCtx,
+			chromedp.Navigate("about:blank"),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				frameTree, err := page.GetFrameTree().Do(ctx)
+				if err != nil {
+					return err
+				}
+				return page.SetDocumentContent(frameTree.Frame.ID, html).Do(ctx)
+			}),
+			chromedp.WaitReady("body"),
+			// Defaults to a fixed delay so Tailwind CSS and friends can settle
+			waiter.Wait(),
+			collectHeadings(opts, &headings),
+			printToPDF(opts, &buf),
+		)
+	})
+	if err != nil || !wantsOutline(opts) {
+		return buf, err
 	}
 
-	err := chromedp.Run(taskCtx,
-		chromedp.Navigate("about:blank"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			frameTree, err := page.GetFrameTree().Do(ctx)
-			if err != nil {
-				return err
-			}
-			return page.SetDocumentContent(frameTree.Frame.ID, html).Do(ctx)
-		}),
-		chromedp.WaitReady("body"),
-		// WAITING FOR TAILWIND CSS
-		chromedp.Sleep(3*time.Second),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			printParams := page.PrintToPDF().
-				WithPaperWidth(width).
-				WithPaperHeight(height).
-				WithPrintBackground(true)
-
-			if opts != nil && opts.Margins != nil {
-				printParams = printParams.
-					WithMarginTop(opts.Margins.Top).
-					WithMarginBottom(opts.Margins.Bottom).
-					WithMarginLeft(opts.Margins.Left).
-					WithMarginRight(opts.Margins.Right)
-			}
-			var err error
-			buf, _, err = printParams.Do(ctx)
-			return err
-		}),
-	)
-	return buf, err
+	// The tab is released first: the table of contents needs its own render
+	return c.applyOutline(ctx, buf, headings, opts)
 }
 
 func (c *ChromeConverter) ConvertURL(ctx context.Context, url string, opts *models.PDFOptions) ([]byte, error) {
-	c.semaphore <- struct{}{}
-	defer func() { <-c.semaphore }()
-
-	taskCtx, cancel := chromedp.NewContext(c.allocCtx)
-	defer cancel()
-	taskCtx, cancel = context.WithTimeout(taskCtx, 60*time.Second)
-	defer cancel()
-
 	var buf []byte
-	err := chromedp.Run(taskCtx,
-		chromedp.Navigate(url),
-		chromedp.WaitReady("body"),
-		chromedp.Sleep(2*time.Second),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			_, _, err := page.PrintToPDF().WithPrintBackground(true).Do(ctx)
-			return err
-		}),
-	)
+	err := c.withTab(ctx, 60*time.Second, func(taskCtx context.Context) error {
+		waiter := newReadinessWaiter(taskCtx, opts, 2*time.Second)
+
+		return chromedp.Run(taskCtx,
+			chromedp.Navigate(url),
+			chromedp.WaitReady("body"),
+			waiter.Wait(),
+			printToPDF(opts, &buf),
+		)
+	})
 	return buf, err
 }
 
-// ConvertMarkdown wraps markdown in HTML styles and converts
-func (c *ChromeConverter) ConvertMarkdown(ctx context.Context, markdown string, opts *models.PDFOptions) ([]byte, error) {
-	// Simple wrapper style
-	html := fmt.Sprintf(`<html>
-		<head><style>body { font-family: sans-serif; padding: 20px; }</style></head>
-		<body><pre>%s</pre></body>
-		</html>`, markdown)
-	return c.ConvertHTML(ctx, html, opts)
+// grayscaleScript desaturates the whole page before printing
+const grayscaleScript = `(() => {
+	const style = document.createElement('style');
+	style.textContent = 'html { filter: grayscale(100%) !important; }';
+	document.head.appendChild(style);
+	return true;
+})()`
+
+// printToPDF applies the page-level options and prints the loaded page into buf.
+// Every conversion path goes through here so options behave the same everywhere.
+func printToPDF(opts *models.PDFOptions, buf *[]byte) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if opts != nil && opts.Grayscale {
+			var ok bool
+			if err := chromedp.Evaluate(grayscaleScript, &ok).Do(ctx); err != nil {
+				return fmt.Errorf("failed to apply grayscale: %w", err)
+			}
+		}
+
+		var err error
+		*buf, _, err = buildPrintParams(opts).Do(ctx)
+		return err
+	})
+}
+
+// buildPrintParams translates PDFOptions into Chrome print parameters
+func buildPrintParams(opts *models.PDFOptions) *page.PrintToPDFParams {
+	dims := models.PageA4.GetDimensions()
+	if opts != nil {
+		dims = opts.PageDimensions()
+	}
+
+	scale := 1.0
+	if opts != nil && opts.Scale > 0 {
+		scale = math.Min(math.Max(opts.Scale, 0.1), 2.0)
+	}
+
+	printParams := page.PrintToPDF().
+		WithPaperWidth(dims.Width).
+		WithPaperHeight(dims.Height).
+		WithScale(scale).
+		WithPrintBackground(opts.ShouldPrintBackground())
+
+	if opts == nil {
+		return printParams
+	}
+
+	if opts.Margins != nil {
+		printParams = printParams.
+			WithMarginTop(opts.Margins.Top).
+			WithMarginBottom(opts.Margins.Bottom).
+			WithMarginLeft(opts.Margins.Left).
+			WithMarginRight(opts.Margins.Right)
+	}
+
+	if opts.Images != nil && opts.Images.PageFitImage {
+		// Image conversions size each page with @page rules
+		printParams = printParams.WithPreferCSSPageSize(true)
+	}
+
+	if HasHeaderFooter(opts.HeaderFooter) {
+		header, footer := buildHeaderFooterTemplates(opts.HeaderFooter, opts.Margins)
+		printParams = printParams.
+			WithDisplayHeaderFooter(true).
+			WithHeaderTemplate(header).
+			WithFooterTemplate(footer)
+	}
+
+	return printParams
+}
+
+// ConvertMarkdown renders Markdown to styled HTML and converts. It returns
+// the options to post-process with: a copy of opts whose Metadata has any
+// empty fields filled in from the front matter. opts itself is unchanged.
+func (c *ChromeConverter) ConvertMarkdown(ctx context.Context, markdown string, opts *models.PDFOptions) ([]byte, *models.PDFOptions, error) {
+	var mdOpts *models.MarkdownOptions
+	if opts != nil {
+		mdOpts = opts.Markdown
+	}
+
+	rendered, err := RenderMarkdown(markdown, mdOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rendered.Metadata != nil {
+		merged := models.PDFOptions{}
+		if opts != nil {
+			merged = *opts
+		}
+		merged.Metadata = MergeMetadata(merged.Metadata, rendered.Metadata)
+		opts = &merged
+	}
+	pdf, err := c.ConvertHTML(ctx, rendered.HTML, opts)
+	return pdf, opts, err
 }
 
-// ConvertImage wraps an image in HTML and converts
+// ConvertImage converts a single image using the same layout as ConvertImages
 func (c *ChromeConverter) ConvertImage(ctx context.Context, imgBase64 string, opts *models.PDFOptions) ([]byte, error) {
-	html := fmt.Sprintf(`<html><body style="margin:0;display:flex;justify-content:center;align-items:center;">
-		<img src="data:image;base64,%s" style="max-width:100%%;max-height:100%%;" />
-		</body></html>`, imgBase64)
-	return c.ConvertHTML(ctx, html, opts)
+	return c.ConvertImages(ctx, []string{imgBase64}, opts)
 }
 
+// ConvertImages places every image on its own page or packs them into a
+// grid, as configured by opts.Images
 func (c *ChromeConverter) ConvertImages(ctx context.Context, imgs []string, opts *models.PDFOptions) ([]byte, error) {
-	// Basic implementation taking first image for now to satisfy interface
-	if len(imgs) > 0 {
-		return c.ConvertImage(ctx, imgs[0], opts)
+	if len(imgs) == 0 {
+		return nil, fmt.Errorf("no images provided")
 	}
-	return nil, fmt.Errorf("no images provided")
+
+	images := make([]*imageInfo, 0, len(imgs))
+	for i, img := range imgs {
+		data, err := decodeImageInput(img)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		images = append(images, inspectImage(data))
+	}
+
+	// JPEG, PNG and GIF don't need a browser unless Chrome-only features are used
+	if canWriteImagesNatively(images, opts) {
+		return writeImagesPDF(images, opts)
+	}
+
+	imgOpts := &models.ImageOptions{}
+	printOpts := &models.PDFOptions{}
+	if opts != nil {
+		copied := *opts
+		printOpts = &copied
+		if opts.Images != nil {
+			imgOpts = opts.Images
+		}
+	}
+	if imgOpts.PageFitImage && imgOpts.Layout != ImageLayoutGrid {
+		// Paper size comes from the per-image @page rules
+		printOpts.Margins = &models.Margins{}
+	} else if printOpts.Margins == nil {
+		// Sheets are laid out without margins, like the native writer;
+		// Chrome's default margins would push each onto a second page
+		printOpts.Margins = &models.Margins{}
+	}
+	printOpts.WaitFor = nil
+	printOpts.Outline = nil
+
+	return c.ConvertHTML(ctx, buildImagesHTML(images, imgOpts, printOpts), printOpts)
 }
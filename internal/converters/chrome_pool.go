@@ -0,0 +1,278 @@
+package converters
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pdf-forge/internal/models"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// ChromeConfig configures the Chrome converter and its browser pool
+type ChromeConfig struct {
+	MaxWorkers  int // Number of warm browsers, one render at a time each
+	MaxRenders  int // Recycle a browser after this many renders (0 = never)
+	MaxMemoryMB int // Recycle a browser whose process tree exceeds this RSS (0 = never)
+
+	// RemoteURLs attaches to existing headless Chrome instances
+	// (ws:// or http:// DevTools endpoints) instead of launching one
+	RemoteURLs []string
+}
+
+// browserWorker is a warm browser process with one reusable tab
+type browserWorker struct {
+	id          int
+	cancelAlloc context.CancelFunc
+	tabCtx      context.Context
+	cancelTab   context.CancelFunc
+	renders     int
+}
+
+// browserPool hands out warm browsers and replaces them when they
+// crash, hit the render limit or grow past the memory limit
+type browserPool struct {
+	cfg  ChromeConfig
+	idle chan *browserWorker
+
+	newAllocator func() (context.Context, context.CancelFunc, error)
+
+	mu      sync.Mutex
+	workers map[int]*browserWorker
+	closed  bool
+
+	renders  atomic.Int64
+	recycled atomic.Int64
+	crashes  atomic.Int64
+}
+
+func newBrowserPool(cfg ChromeConfig, newAllocator func() (context.Context, context.CancelFunc, error)) (*browserPool, error) {
+	p := &browserPool{
+		cfg:          cfg,
+		idle:         make(chan *browserWorker, cfg.MaxWorkers),
+		newAllocator: newAllocator,
+		workers:      make(map[int]*browserWorker),
+	}
+
+	for i := 0; i < cfg.MaxWorkers; i++ {
+		w, err := p.spawn(i)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.idle <- w
+	}
+
+	return p, nil
+}
+
+// spawn starts a browser and warms up its tab
+func (p *browserPool) spawn(id int) (*browserWorker, error) {
+	allocCtx, cancelAlloc, err := p.newAllocator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start browser %d: %w", id, err)
+	}
+	tabCtx, cancelTab := chromedp.NewContext(allocCtx)
+
+	// The first Run starts the browser; it must use the long-lived tab
+	// context, otherwise the browser would die with a request timeout
+	if err := chromedp.Run(tabCtx); err != nil {
+		cancelTab()
+		cancelAlloc()
+		return nil, fmt.Errorf("failed to start browser %d: %w", id, err)
+	}
+
+	w := &browserWorker{
+		id:          id,
+		cancelAlloc: cancelAlloc,
+		tabCtx:      tabCtx,
+		cancelTab:   cancelTab,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		w.close()
+		return nil, fmt.Errorf("browser pool is closed")
+	}
+	p.workers[id] = w
+	return w, nil
+}
+
+// acquire waits for a free browser
+func (p *browserPool) acquire(ctx context.Context) (*browserWorker, error) {
+	select {
+	case w := <-p.idle:
+		if w.tabCtx != nil {
+			return w, nil
+		}
+		// A previous respawn failed; try again now
+		nw, err := p.spawn(w.id)
+		if err != nil {
+			p.idle <- w
+			return nil, err
+		}
+		return nw, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release returns a browser to the pool, replacing it when needed
+func (p *browserPool) release(w *browserWorker, renderErr error) {
+	w.renders++
+	p.renders.Add(1)
+
+	recycle := false
+	switch {
+	case renderErr != nil && !w.alive():
+		p.crashes.Add(1)
+		recycle = true
+	case p.cfg.MaxRenders > 0 && w.renders >= p.cfg.MaxRenders:
+		p.recycled.Add(1)
+		recycle = true
+	case p.cfg.MaxMemoryMB > 0 && w.memoryMB() > int64(p.cfg.MaxMemoryMB):
+		p.recycled.Add(1)
+		recycle = true
+	}
+
+	if !recycle {
+		// Don't leak cookies between documents
+		chromedp.Run(w.tabCtx, network.ClearBrowserCookies())
+		p.idle <- w
+		return
+	}
+
+	go p.replace(w)
+}
+
+// replace closes a worker and puts a fresh browser in its slot
+func (p *browserPool) replace(w *browserWorker) {
+	p.mu.Lock()
+	delete(p.workers, w.id)
+	closed := p.closed
+	p.mu.Unlock()
+
+	w.close()
+	if closed {
+		return
+	}
+
+	nw, err := p.spawn(w.id)
+	if err != nil {
+		// Keep the slot; acquire will retry the spawn
+		nw = &browserWorker{id: w.id}
+	}
+	p.idle <- nw
+}
+
+// status reports the pool state for health checks
+func (p *browserPool) status() *models.PoolStatus {
+	p.mu.Lock()
+	live := len(p.workers)
+	p.mu.Unlock()
+
+	return &models.PoolStatus{
+		Browsers: live,
+		Renders:  p.renders.Load(),
+		Recycled: p.recycled.Load(),
+		Crashes:  p.crashes.Load(),
+	}
+}
+
+func (p *browserPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for id, w := range p.workers {
+		w.close()
+		delete(p.workers, id)
+	}
+}
+
+func (w *browserWorker) close() {
+	if w.cancelTab != nil {
+		w.cancelTab()
+	}
+	if w.cancelAlloc != nil {
+		w.cancelAlloc()
+	}
+}
+
+// alive checks that both the browser and the tab still respond
+func (w *browserWorker) alive() bool {
+	if w.tabCtx.Err() != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(w.tabCtx, 3*time.Second)
+	defer cancel()
+
+	c := chromedp.FromContext(w.tabCtx)
+	if c == nil || c.Browser == nil {
+		return false
+	}
+	if _, _, _, _, _, err := browser.GetVersion().Do(cdp.WithExecutor(ctx, c.Browser)); err != nil {
+		return false
+	}
+
+	var ok bool
+	return chromedp.Run(ctx, chromedp.Evaluate(`true`, &ok)) == nil && ok
+}
+
+// memoryMB returns the resident memory of the browser process tree.
+// Only local browsers on Linux are measured; others report 0.
+func (w *browserWorker) memoryMB() int64 {
+	c := chromedp.FromContext(w.tabCtx)
+	if c == nil || c.Browser == nil || c.Browser.Process() == nil {
+		return 0
+	}
+	return processTreeRSS(c.Browser.Process().Pid) / 1024
+}
+
+// processTreeRSS sums VmRSS (in kB) of pid and all its descendants
+func processTreeRSS(pid int) int64 {
+	total := readRSS(pid)
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		return total
+	}
+	for _, child := range strings.Fields(string(data)) {
+		if childPID, err := strconv.Atoi(child); err == nil {
+			total += processTreeRSS(childPID)
+		}
+	}
+	return total
+}
+
+func readRSS(pid int) int64 {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "VmRSS:") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				kb, _ := strconv.ParseInt(fields[1], 10, 64)
+				return kb
+			}
+		}
+	}
+	return 0
+}
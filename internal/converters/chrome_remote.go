@@ -0,0 +1,161 @@
+package converters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pdf-forge/internal/models"
+
+	"github.com/chromedp/chromedp"
+)
+
+const remoteHealthInterval = 10 * time.Second
+
+// remoteEndpoint is one headless Chrome reachable over the DevTools protocol
+type remoteEndpoint struct {
+	url     string
+	healthy bool
+}
+
+// remoteEndpoints round-robins browser allocations across remote Chrome
+// instances and skips the ones failing their health check
+type remoteEndpoints struct {
+	mu        sync.Mutex
+	endpoints []*remoteEndpoint
+	next      int
+
+	client *http.Client
+	stop   context.CancelFunc
+}
+
+func newRemoteEndpoints(urls []string) *remoteEndpoints {
+	r := &remoteEndpoints{
+		client: &http.Client{Timeout: 2 * time.Second},
+	}
+	for _, u := range urls {
+		r.endpoints = append(r.endpoints, &remoteEndpoint{url: u, healthy: true})
+	}
+
+	r.checkAll()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stop = cancel
+	go r.healthLoop(ctx)
+
+	return r
+}
+
+// allocate returns an allocator for the next healthy endpoint
+func (r *remoteEndpoints) allocate() (context.Context, context.CancelFunc, error) {
+	r.mu.Lock()
+	candidates := len(r.endpoints)
+	r.mu.Unlock()
+
+	for i := 0; i < candidates; i++ {
+		ep := r.pick()
+		if ep == nil {
+			break
+		}
+		// Probe before handing it out so a freshly dead browser is skipped
+		if !r.check(ep) {
+			continue
+		}
+		ctx, cancel := chromedp.NewRemoteAllocator(context.Background(), ep.url)
+		return ctx, cancel, nil
+	}
+
+	return nil, nil, fmt.Errorf("no healthy remote Chrome endpoint")
+}
+
+// pick advances the round-robin cursor to the next healthy endpoint
+func (r *remoteEndpoints) pick() *remoteEndpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := 0; i < len(r.endpoints); i++ {
+		ep := r.endpoints[r.next%len(r.endpoints)]
+		r.next++
+		if ep.healthy {
+			return ep
+		}
+	}
+	return nil
+}
+
+func (r *remoteEndpoints) healthLoop(ctx context.Context) {
+	ticker := time.NewTicker(remoteHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkAll()
+		}
+	}
+}
+
+func (r *remoteEndpoints) checkAll() {
+	for _, ep := range r.endpoints {
+		r.check(ep)
+	}
+}
+
+// check queries /json/version and records the endpoint health
+func (r *remoteEndpoints) check(ep *remoteEndpoint) bool {
+	healthy := false
+	if versionURL, err := devtoolsVersionURL(ep.url); err == nil {
+		if resp, err := r.client.Get(versionURL); err == nil {
+			resp.Body.Close()
+			healthy = resp.StatusCode == http.StatusOK
+		}
+	}
+
+	r.mu.Lock()
+	ep.healthy = healthy
+	r.mu.Unlock()
+	return healthy
+}
+
+func (r *remoteEndpoints) status() []models.EndpointStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]models.EndpointStatus, 0, len(r.endpoints))
+	for _, ep := range r.endpoints {
+		result = append(result, models.EndpointStatus{URL: ep.url, Healthy: ep.healthy})
+	}
+	return result
+}
+
+func (r *remoteEndpoints) close() {
+	r.stop()
+}
+
+// devtoolsVersionURL maps ws://host:9222/devtools/browser/... or
+// http://host:9222 to http://host:9222/json/version
+func devtoolsVersionURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	u.Path = "/json/version"
+	u.RawQuery = ""
+	return u.String(), nil
+}
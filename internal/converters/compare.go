@@ -0,0 +1,254 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// Compare renders the selected pages of two PDFs (all when pageRange is
+// empty) and reports how much each page changed, with highlight images
+// of the changed pixels and a line diff of the page text. The first PDF
+// is the reference, e.g. a golden file.
+func (m *PDFManipulator) Compare(ctx context.Context, pdfA, pdfB []byte, pageRange string, opts *models.CompareOptions) (*models.CompareResult, error) {
+	if opts == nil {
+		opts = &models.CompareOptions{}
+	}
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+	if dpi > maxRenderDPI {
+		return nil, fmt.Errorf("%w: dpi must be at most %d", ErrInvalidOptions, maxRenderDPI)
+	}
+	if opts.Threshold < 0 || opts.Threshold > 100 {
+		return nil, fmt.Errorf("threshold must be between 0 and 100")
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "compare-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	pathA := filepath.Join(workDir, "a.pdf")
+	pathB := filepath.Join(workDir, "b.pdf")
+	if err := os.WriteFile(pathA, pdfA, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+	if err := os.WriteFile(pathB, pdfB, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	countA, err := countPages(ctx, pathA)
+	if err != nil {
+		return nil, err
+	}
+	countB, err := countPages(ctx, pathB)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectPages(pageRange, max(countA, countB))
+	if err != nil {
+		return nil, err
+	}
+
+	textA, err := comparedText(ctx, pathA, pages, countA)
+	if err != nil {
+		return nil, err
+	}
+	textB, err := comparedText(ctx, pathB, pages, countB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.CompareResult{PagesA: countA, PagesB: countB, Passed: countA == countB}
+	for _, p := range pages {
+		imgA, err := comparedPage(ctx, workDir, pathA, "a", p, countA, dpi)
+		if err != nil {
+			return nil, err
+		}
+		imgB, err := comparedPage(ctx, workDir, pathB, "b", p, countB, dpi)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := models.PageDiff{Page: p}
+		highlight, changed, total := diffImages(imgA, imgB, opts.Tolerance)
+		if total > 0 {
+			diff.Score = math.Round(float64(changed)/float64(total)*100*1000) / 1000
+		}
+		diff.Passed = diff.Score <= opts.Threshold && imgA != nil && imgB != nil
+		if changed > 0 {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, highlight); err != nil {
+				return nil, fmt.Errorf("failed to encode diff image: %w", err)
+			}
+			diff.Image = base64.StdEncoding.EncodeToString(buf.Bytes())
+		}
+		diff.TextDiff = diffLines(textA[p], textB[p])
+
+		if !diff.Passed {
+			result.Passed = false
+			result.Failed++
+		}
+		result.Pages = append(result.Pages, diff)
+	}
+	return result, nil
+}
+
+// comparedText returns the text lines of the selected pages a document has
+func comparedText(ctx context.Context, pdfPath string, pages []int, count int) (map[int][]string, error) {
+	var own []int
+	for _, p := range pages {
+		if p <= count {
+			own = append(own, p)
+		}
+	}
+	lines := make(map[int][]string, len(own))
+	if len(own) == 0 {
+		return lines, nil
+	}
+
+	texts, err := pageText(ctx, pdfPath, own, TextModePlain)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range texts {
+		for _, line := range strings.Split(t.Text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines[t.Page] = append(lines[t.Page], line)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// comparedPage renders one page to an image, nil when the document is
+// shorter
+func comparedPage(ctx context.Context, workDir, pdfPath, name string, page, count, dpi int) (image.Image, error) {
+	if page > count {
+		return nil, nil
+	}
+	images, err := renderPages(ctx, pdfPath, filepath.Join(workDir, fmt.Sprintf("%s%d", name, page)), "png", dpi, page, page)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) != 1 {
+		return nil, fmt.Errorf("failed to render page %d", page)
+	}
+	data, err := os.ReadFile(images[0])
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(images[0])
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page %d: %w", page, err)
+	}
+	return img, nil
+}
+
+// diffImages compares two page images over the area both cover, counting
+// pixels outside either page as changed. The highlight image shows the
+// second page faded with changed pixels in red.
+func diffImages(a, b image.Image, tolerance int) (*image.RGBA, int, int) {
+	var boundsA, boundsB image.Rectangle
+	if a != nil {
+		boundsA = a.Bounds()
+	}
+	if b != nil {
+		boundsB = b.Bounds()
+	}
+	width := max(boundsA.Dx(), boundsB.Dx())
+	height := max(boundsA.Dy(), boundsB.Dy())
+	highlight := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	pixel := func(img image.Image, bounds image.Rectangle, x, y int) (color.Color, bool) {
+		if img == nil || x >= bounds.Dx() || y >= bounds.Dy() {
+			return nil, false
+		}
+		return img.At(bounds.Min.X+x, bounds.Min.Y+y), true
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	changed := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			ca, okA := pixel(a, boundsA, x, y)
+			cb, okB := pixel(b, boundsB, x, y)
+			if !okA || !okB || colorDistance(ca, cb) > tolerance {
+				changed++
+				highlight.SetRGBA(x, y, red)
+				continue
+			}
+			// Fade the unchanged content so the red stands out
+			gray := color.GrayModel.Convert(cb).(color.Gray).Y
+			v := 191 + gray/4
+			highlight.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return highlight, changed, width * height
+}
+
+// colorDistance is the largest difference of the 8-bit channels
+func colorDistance(a, b color.Color) int {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	d := 0
+	for _, pair := range [][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}} {
+		diff := int(pair[0]>>8) - int(pair[1]>>8)
+		if diff < 0 {
+			diff = -diff
+		}
+		d = max(d, diff)
+	}
+	return d
+}
+
+// diffLines returns the lines removed from a ("- ") and added in b ("+ "),
+// in order, from their longest common subsequence
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the common length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
@@ -0,0 +1,161 @@
+package converters
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrNoXMP is returned when a PDF's catalog has no XMP metadata stream
+var ErrNoXMP = errors.New("PDF has no XMP metadata")
+
+// facturXNamespace is the XMP namespace of the Factur-X / ZUGFeRD 2.x schema
+const facturXNamespace = "urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#"
+
+// facturXLevels maps profiles to their XMP ConformanceLevel
+var facturXLevels = map[string]string{
+	"MINIMUM": "MINIMUM",
+	"BASIC":   "BASIC",
+	"EN16931": "EN 16931",
+}
+
+// xmpExtensionPattern matches the rdf:Description blocks carrying Factur-X
+// properties or PDF/A extension schemas, which writeMetadata keeps
+var xmpExtensionPattern = regexp.MustCompile(`(?s)<rdf:Description[^>]*xmlns:(?:fx|pdfaExtension)=.*?</rdf:Description>\n?`)
+
+// facturXXMP renders the Factur-X properties and the PDF/A extension
+// schema that declares them
+func facturXXMP(fileName, level string) string {
+	property := func(name, description string) string {
+		return fmt.Sprintf(`<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>%s</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>%s</pdfaProperty:description>
+</rdf:li>
+`, name, description)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<rdf:Description rdf:about="" xmlns:fx="%s">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>%s</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>%s</fx:ConformanceLevel>
+</rdf:Description>
+`, facturXNamespace, fileName, level)
+	fmt.Fprintf(&b, `<rdf:Description rdf:about=""
+ xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/"
+ xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#"
+ xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas><rdf:Bag><rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>%s</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property><rdf:Seq>
+`, facturXNamespace)
+	b.WriteString(property("DocumentFileName", "The name of the embedded XML document"))
+	b.WriteString(property("DocumentType", "The type of the hybrid document in capital letters, e.g. INVOICE or ORDER"))
+	b.WriteString(property("Version", "The actual version of the standard applying to the embedded XML document"))
+	b.WriteString(property("ConformanceLevel", "The conformance level of the embedded XML document"))
+	b.WriteString("</rdf:Seq></pdfaSchema:property>\n</rdf:li></rdf:Bag></pdfaExtension:schemas>\n</rdf:Description>\n")
+	return b.String()
+}
+
+// AddFacturXMetadata declares an embedded Factur-X invoice in the XMP
+// packet of a PDF/A-3 document. The invoice itself is attached by
+// ConvertToPDFA.
+func (p *PDFProcessor) AddFacturXMetadata(pdfData []byte, fileName, profile string) ([]byte, error) {
+	level, ok := facturXLevels[profile]
+	if !ok {
+		return nil, fmt.Errorf("unsupported Factur-X profile %q", profile)
+	}
+
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp(p.tempDir, "facturx-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+
+	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	metadataID, xmp, header, err := readXMP(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace a previous declaration rather than adding a second one
+	packet := xmpExtensionPattern.ReplaceAllString(xmp, "")
+	end := strings.LastIndex(packet, "</rdf:RDF>")
+	if end < 0 {
+		return nil, fmt.Errorf("XMP metadata has no rdf:RDF element")
+	}
+	packet = packet[:end] + facturXXMP(fileName, level) + packet[end:]
+
+	update := map[string]interface{}{
+		"obj:" + metadataID + " 0 R": xmpStream(packet),
+	}
+	if err := updateQPDFObjects(ctx, workDir, inputPath, outputPath, header, update); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// readXMP returns the object number and contents of the catalog's XMP
+// metadata stream together with the qpdf JSON header
+func readXMP(ctx context.Context, pdfPath string) (string, string, json.RawMessage, error) {
+	_, objs, err := readQPDFObjects(ctx, pdfPath, "trailer")
+	if err != nil {
+		return "", "", nil, err
+	}
+	rootID, ok := qpdfRef(objs["trailer"].Value["/Root"])
+	if !ok {
+		return "", "", nil, fmt.Errorf("PDF has no document catalog")
+	}
+	_, objs, err = readQPDFObjects(ctx, pdfPath, rootID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	metadataID, ok := qpdfRef(objs["obj:"+rootID+" 0 R"].Value["/Metadata"])
+	if !ok {
+		return "", "", nil, ErrNoXMP
+	}
+	header, objs, err := readQPDFObjects(ctx, pdfPath, metadataID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	stream := objs["obj:"+metadataID+" 0 R"].Stream
+	if stream == nil {
+		return "", "", nil, ErrNoXMP
+	}
+	data, err := base64.StdEncoding.DecodeString(stream.Data)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("unreadable XMP metadata: %w", err)
+	}
+	return metadataID, string(data), header, nil
+}
+
+// xmpStream is an uncompressed XMP metadata stream for a qpdf JSON update
+func xmpStream(packet string) qpdfObject {
+	return qpdfObject{Stream: &qpdfStream{
+		Dict: map[string]json.RawMessage{
+			"/Type":    qpdfNameValue("Metadata"),
+			"/Subtype": qpdfNameValue("XML"),
+		},
+		Data: base64.StdEncoding.EncodeToString([]byte(packet)),
+	}}
+}
@@ -0,0 +1,459 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// Field flags (PDF 32000-1, tables 221, 226, 228, 230)
+const (
+	fieldReadOnly    = 1 << 0
+	fieldRequired    = 1 << 1
+	fieldCombo       = 1 << 17
+	fieldEdit        = 1 << 18
+	fieldMultiSelect = 1 << 21
+)
+
+// qpdfFormField is one widget entry of qpdf's JSON acroform section
+type qpdfFormField struct {
+	Object       string          `json:"object"`
+	FullName     string          `json:"fullname"`
+	FieldType    string          `json:"fieldtype"`
+	FieldFlags   int             `json:"fieldflags"`
+	Value        json.RawMessage `json:"value"`
+	DefaultValue json.RawMessage `json:"defaultvalue"`
+	Choices      []string        `json:"choices"`
+	IsText       bool            `json:"istext"`
+	IsCheckbox   bool            `json:"ischeckbox"`
+	IsRadio      bool            `json:"isradiobutton"`
+	IsChoice     bool            `json:"ischoice"`
+	Page         int             `json:"pageposfrom1"`
+	Annotation   struct {
+		Object string `json:"object"`
+	} `json:"annotation"`
+}
+
+// pdfForm is the interactive form of a document with its objects
+type pdfForm struct {
+	header json.RawMessage
+	fields []qpdfFormField
+	objs   map[string]qpdfObject
+}
+
+// readForm reads the form fields and all objects (without stream data)
+func readForm(ctx context.Context, pdfPath string) (*pdfForm, error) {
+	cmd := exec.CommandContext(ctx, "qpdf", "--json=2", "--json-key=acroform", "--json-key=qpdf",
+		"--json-stream-data=none", pdfPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read form: %w - %s", err, stderr.String())
+	}
+
+	var doc struct {
+		AcroForm struct {
+			Fields []qpdfFormField `json:"fields"`
+		} `json:"acroform"`
+		QPDF []json.RawMessage `json:"qpdf"`
+	}
+	if err := json.Unmarshal(output, &doc); err != nil || len(doc.QPDF) != 2 {
+		return nil, fmt.Errorf("unexpected qpdf JSON output")
+	}
+	form := &pdfForm{header: doc.QPDF[0], fields: doc.AcroForm.Fields}
+	if err := json.Unmarshal(doc.QPDF[1], &form.objs); err != nil {
+		return nil, fmt.Errorf("unexpected qpdf JSON objects: %w", err)
+	}
+	return form, nil
+}
+
+// FormFields lists the AcroForm fields of a PDF with their widgets
+func (m *PDFManipulator) FormFields(ctx context.Context, pdf []byte) ([]models.FormField, error) {
+	workDir, err := os.MkdirTemp(m.tempDir, "form-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	form, err := readForm(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// qpdf lists one entry per widget; group them by field
+	var fields []models.FormField
+	index := make(map[string]int)
+	for _, f := range form.fields {
+		widget := models.FormWidget{Page: f.Page}
+		annot := form.objs["obj:"+f.Annotation.Object].Value
+		widget.Rect = annotationRect(annot)
+		if f.IsCheckbox || f.IsRadio {
+			if states := form.onStates(annot); len(states) > 0 {
+				widget.State = states[0]
+			}
+		}
+
+		i, ok := index[f.FullName]
+		if !ok {
+			i = len(fields)
+			index[f.FullName] = i
+			fields = append(fields, models.FormField{
+				Name:     f.FullName,
+				Type:     f.kind(),
+				Value:    fieldValue(f.Value),
+				Default:  fieldValue(f.DefaultValue),
+				Multiple: f.IsChoice && f.FieldFlags&fieldMultiSelect != 0,
+				ReadOnly: f.FieldFlags&fieldReadOnly != 0,
+				Required: f.FieldFlags&fieldRequired != 0,
+			})
+			if f.IsChoice {
+				fields[i].Options = f.Choices
+			}
+		}
+		fields[i].Widgets = append(fields[i].Widgets, widget)
+		if widget.State != "" && !containsString(fields[i].Options, widget.State) {
+			fields[i].Options = append(fields[i].Options, widget.State)
+		}
+	}
+	return fields, nil
+}
+
+// FillForm sets field values, regenerating text appearances, and
+// optionally flattens the form into the page content
+func (m *PDFManipulator) FillForm(ctx context.Context, pdf []byte, values map[string]interface{}, flatten bool) ([]byte, error) {
+	if len(values) == 0 && !flatten {
+		return nil, fmt.Errorf("no field values provided")
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "form-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	filledPath := filepath.Join(workDir, "filled.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	form, err := readForm(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(form.fields) == 0 {
+		return nil, fmt.Errorf("PDF has no form fields")
+	}
+
+	widgets := make(map[string][]qpdfFormField)
+	for _, f := range form.fields {
+		widgets[f.FullName] = append(widgets[f.FullName], f)
+	}
+	var unknown []string
+	for name := range values {
+		if _, ok := widgets[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown form fields: %s", strings.Join(unknown, ", "))
+	}
+
+	// Objects are replaced whole, so edits collect on copies of their values
+	changed := make(map[string]map[string]json.RawMessage)
+	edit := func(ref string) map[string]json.RawMessage {
+		if d, ok := changed[ref]; ok {
+			return d
+		}
+		d := make(map[string]json.RawMessage)
+		for k, v := range form.objs["obj:"+ref].Value {
+			d[k] = v
+		}
+		changed[ref] = d
+		return d
+	}
+
+	for name, value := range values {
+		group := widgets[name]
+		f := group[0]
+		if f.FieldFlags&fieldReadOnly != 0 {
+			return nil, fmt.Errorf("field %q is read-only", name)
+		}
+		field := edit(form.terminalField(f.Object))
+
+		switch {
+		case f.IsText:
+			field["/V"] = qpdfTextValue(formString(value))
+
+		case f.IsCheckbox || f.IsRadio:
+			state, err := form.buttonState(group, value)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			field["/V"] = qpdfNameValue(state)
+			for _, w := range group {
+				as := "Off"
+				if containsString(form.onStates(form.objs["obj:"+w.Annotation.Object].Value), state) {
+					as = state
+				}
+				edit(w.Annotation.Object)["/AS"] = qpdfNameValue(as)
+			}
+
+		case f.IsChoice:
+			selected := formStrings(value)
+			free := f.FieldFlags&fieldCombo != 0 && f.FieldFlags&fieldEdit != 0
+			for _, s := range selected {
+				if s != "" && !free && !containsString(f.Choices, s) {
+					return nil, fmt.Errorf("field %q has no option %q", name, s)
+				}
+			}
+			switch {
+			case len(selected) == 1:
+				field["/V"] = qpdfTextValue(selected[0])
+			case f.FieldFlags&fieldMultiSelect != 0:
+				items := make([]json.RawMessage, len(selected))
+				for i, s := range selected {
+					items[i] = qpdfTextValue(s)
+				}
+				field["/V"] = qpdfArray(items)
+			default:
+				return nil, fmt.Errorf("field %q takes a single option", name)
+			}
+
+		default:
+			return nil, fmt.Errorf("field %q is a %s field and can't be filled", name, f.kind())
+		}
+	}
+
+	// Ask for new appearance streams, which qpdf generates below
+	if len(values) > 0 {
+		if err := form.requestAppearances(edit); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changed) == 0 {
+		filledPath = inputPath
+	} else {
+		update := make(map[string]interface{}, len(changed))
+		for ref, value := range changed {
+			update["obj:"+ref] = qpdfObject{Value: value}
+		}
+		if err := updateQPDFObjects(ctx, workDir, inputPath, filledPath, form.header, update); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{filledPath, "--generate-appearances"}
+	if flatten {
+		args = append(args, "--flatten-annotations=all")
+	}
+	args = append(args, "--", outputPath)
+	if err := m.runQPDF(args...); err != nil {
+		return nil, fmt.Errorf("failed to update form: %w", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// requestAppearances sets /NeedAppearances on the AcroForm dictionary,
+// which may be an object of its own or inline in the catalog
+func (f *pdfForm) requestAppearances(edit func(string) map[string]json.RawMessage) error {
+	rootRef, ok := qpdfRefString(f.objs["trailer"].Value["/Root"])
+	if !ok {
+		return fmt.Errorf("PDF has no document catalog")
+	}
+	catalog := f.objs["obj:"+rootRef].Value
+	if ref, ok := qpdfRefString(catalog["/AcroForm"]); ok {
+		edit(ref)["/NeedAppearances"] = json.RawMessage("true")
+		return nil
+	}
+	var acroForm map[string]json.RawMessage
+	if err := json.Unmarshal(catalog["/AcroForm"], &acroForm); err != nil || acroForm == nil {
+		return fmt.Errorf("PDF has no AcroForm dictionary")
+	}
+	acroForm["/NeedAppearances"] = json.RawMessage("true")
+	edit(rootRef)["/AcroForm"], _ = json.Marshal(acroForm)
+	return nil
+}
+
+// terminalField walks up from a widget to the field holding its value
+func (f *pdfForm) terminalField(ref string) string {
+	for depth := 0; depth < 32; depth++ {
+		obj := f.objs["obj:"+ref].Value
+		if _, ok := obj["/T"]; ok {
+			return ref
+		}
+		parent, ok := qpdfRefString(obj["/Parent"])
+		if !ok {
+			break
+		}
+		ref = parent
+	}
+	return ref
+}
+
+// onStates returns the appearance states of a checkbox or radio widget
+// other than Off
+func (f *pdfForm) onStates(annot map[string]json.RawMessage) []string {
+	ap := f.resolveDict(annot["/AP"])
+	normal := f.resolveDict(ap["/N"])
+	var states []string
+	for key := range normal {
+		if key != "/Off" {
+			states = append(states, key[1:])
+		}
+	}
+	sort.Strings(states)
+	return states
+}
+
+// buttonState maps a requested checkbox or radio value to a state name:
+// true/false, "Off", or one of the widgets' on-states
+func (f *pdfForm) buttonState(group []qpdfFormField, value interface{}) (string, error) {
+	var states []string
+	for _, w := range group {
+		for _, s := range f.onStates(f.objs["obj:"+w.Annotation.Object].Value) {
+			if !containsString(states, s) {
+				states = append(states, s)
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case bool:
+		if !v {
+			return "Off", nil
+		}
+		if len(states) == 0 {
+			return "", fmt.Errorf("no on-state appearance")
+		}
+		return states[0], nil
+	case nil:
+		return "Off", nil
+	}
+	s := formString(value)
+	if s == "Off" || containsString(states, s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("value %q is not one of %s", s, strings.Join(states, ", "))
+}
+
+// resolveDict returns an inline dictionary or the dictionary of a
+// referenced object (a stream's dictionary is not a state map)
+func (f *pdfForm) resolveDict(raw json.RawMessage) map[string]json.RawMessage {
+	if ref, ok := qpdfRefString(raw); ok {
+		return f.objs["obj:"+ref].Value
+	}
+	var dict map[string]json.RawMessage
+	json.Unmarshal(raw, &dict)
+	return dict
+}
+
+// kind names the field type as reported by form_fields
+func (f *qpdfFormField) kind() string {
+	switch {
+	case f.IsText:
+		return "text"
+	case f.IsCheckbox:
+		return "checkbox"
+	case f.IsRadio:
+		return "radio"
+	case f.IsChoice:
+		return "choice"
+	case f.FieldType == "/Sig":
+		return "signature"
+	}
+	return "button"
+}
+
+// fieldValue decodes a qpdf JSON field value: text, a state name or a
+// list of selected options
+func fieldValue(raw json.RawMessage) interface{} {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if raw[0] == '[' {
+		var items []json.RawMessage
+		json.Unmarshal(raw, &items)
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			if v, ok := fieldValue(item).(string); ok {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	if name := qpdfName(raw); name != "" {
+		return name
+	}
+	if text := qpdfText(raw); text != "" {
+		return text
+	}
+	return nil
+}
+
+// annotationRect converts an annotation /Rect to x, y, width, height
+func annotationRect(annot map[string]json.RawMessage) [4]float64 {
+	var r []float64
+	if json.Unmarshal(annot["/Rect"], &r) != nil || len(r) != 4 {
+		return [4]float64{}
+	}
+	return [4]float64{
+		math.Min(r[0], r[2]), math.Min(r[1], r[3]),
+		math.Abs(r[2] - r[0]), math.Abs(r[3] - r[1]),
+	}
+}
+
+// formString renders a JSON value supplied for a text field
+func formString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// formStrings accepts one option or a list of options
+func formStrings(value interface{}) []string {
+	if list, ok := value.([]interface{}); ok {
+		values := make([]string, len(list))
+		for i, v := range list {
+			values[i] = formString(v)
+		}
+		return values
+	}
+	return []string{formString(value)}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
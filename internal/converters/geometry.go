@@ -0,0 +1,662 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// nupGrids are the columns and rows per sheet for nup, used either way round
+var nupGrids = map[int][2]int{2: {2, 1}, 4: {2, 2}, 6: {3, 2}, 9: {3, 3}}
+
+// pageBoxKeys maps crop box names to page dictionary keys
+var pageBoxKeys = map[string]string{
+	"crop":  "/CropBox",
+	"trim":  "/TrimBox",
+	"bleed": "/BleedBox",
+	"art":   "/ArtBox",
+}
+
+// imposeSlot places a source page into a cell of an output sheet
+type imposeSlot struct {
+	sheet int        // Output page, from 1
+	page  int        // Source page, from 1
+	cell  [4]float64 // x, y, width, height on the sheet
+}
+
+// NUp places 2, 4, 6 or 9 of the selected pages on each sheet, left to
+// right and top to bottom. Without a page size the sheets are the size of
+// the first page, turned to whichever orientation fits the pages largest.
+func (m *PDFManipulator) NUp(ctx context.Context, pdf []byte, pageRange string, opts *models.LayoutOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &models.LayoutOptions{}
+	}
+	perSheet := opts.PerSheet
+	if perSheet == 0 {
+		perSheet = 4
+	}
+	grid, ok := nupGrids[perSheet]
+	if !ok {
+		return nil, fmt.Errorf("per_sheet must be 2, 4, 6 or 9")
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "nup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	boxes, err := readPageBoxes(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectPages(pageRange, len(boxes))
+	if err != nil {
+		return nil, err
+	}
+	first := boxes[pages[0]-1]
+	sheet, sized, err := layoutSize(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !sized {
+		sheet = first
+	}
+
+	// Pick the orientation and grid direction that scale the pages least
+	var sheetW, sheetH, best float64
+	var cols, rows int
+	for _, o := range []models.Orientation{models.Portrait, models.Landscape} {
+		if opts.Orientation != "" && opts.Orientation != o {
+			continue
+		}
+		w, h := orient(sheet, o)
+		for _, g := range [][2]int{{grid[0], grid[1]}, {grid[1], grid[0]}} {
+			scale := math.Min(w/float64(g[0])/first.Width, h/float64(g[1])/first.Height)
+			if scale > best {
+				best, sheetW, sheetH, cols, rows = scale, w, h, g[0], g[1]
+			}
+		}
+	}
+	if best == 0 {
+		return nil, fmt.Errorf("invalid orientation %q", opts.Orientation)
+	}
+
+	cellW, cellH := sheetW/float64(cols), sheetH/float64(rows)
+	slots := make([]imposeSlot, len(pages))
+	for i, p := range pages {
+		col, row := i%perSheet%cols, i%perSheet/cols
+		slots[i] = imposeSlot{
+			sheet: i/perSheet + 1,
+			page:  p,
+			cell:  [4]float64{float64(col) * cellW, sheetH - float64(row+1)*cellH, cellW, cellH},
+		}
+	}
+	sheets := (len(pages) + perSheet - 1) / perSheet
+	return m.impose(ctx, workDir, inputPath, sheetW, sheetH, sheets, slots, opts.Border)
+}
+
+// Booklet imposes the selected pages for saddle stitching: two pages side
+// by side on each side of a sheet, ordered so the folded stack reads in
+// sequence. The page count is padded with blank pages to a multiple of
+// four. Without a page size each side is two pages wide.
+func (m *PDFManipulator) Booklet(ctx context.Context, pdf []byte, pageRange string, opts *models.LayoutOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &models.LayoutOptions{}
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "booklet-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	boxes, err := readPageBoxes(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectPages(pageRange, len(boxes))
+	if err != nil {
+		return nil, err
+	}
+	first := boxes[pages[0]-1]
+	sheetW, sheetH := 2*first.Width, first.Height
+	sheet, sized, err := layoutSize(opts)
+	if err != nil {
+		return nil, err
+	}
+	if sized {
+		orientation := opts.Orientation
+		if orientation == "" {
+			orientation = models.Landscape
+		}
+		sheetW, sheetH = orient(sheet, orientation)
+	}
+
+	// Side 2k+1 (front) holds pages n-2k and 2k+1, side 2k+2 (back) pages
+	// 2k+2 and n-2k-1; positions past the last page stay blank
+	n := (len(pages) + 3) / 4 * 4
+	half := sheetW / 2
+	var slots []imposeSlot
+	for k := 0; k < n/4; k++ {
+		sides := [2][2]int{{n - 2*k, 2*k + 1}, {2*k + 2, n - 2*k - 1}}
+		for side, pair := range sides {
+			for pos, idx := range pair {
+				if idx > len(pages) {
+					continue
+				}
+				slots = append(slots, imposeSlot{
+					sheet: 2*k + side + 1,
+					page:  pages[idx-1],
+					cell:  [4]float64{float64(pos) * half, 0, half, sheetH},
+				})
+			}
+		}
+	}
+	return m.impose(ctx, workDir, inputPath, sheetW, sheetH, n/2, slots, false)
+}
+
+// impose lays source pages into cells of new sheets with qpdf. qpdf scales
+// an overlay page into the TrimBox of its target, so each slot first gets
+// a sheet-sized page of its own with the TrimBox on its cell. Those pages
+// are then widened back to the sheet and stacked onto the output sheets,
+// one overlay per position.
+func (m *PDFManipulator) impose(ctx context.Context, workDir, inputPath string, sheetW, sheetH float64, sheets int, slots []imposeSlot, border bool) ([]byte, error) {
+	cellsPath := filepath.Join(workDir, "cells.pdf")
+	placedPath := filepath.Join(workDir, "placed.pdf")
+	widenedPath := filepath.Join(workDir, "widened.pdf")
+	sheetsPath := filepath.Join(workDir, "sheets.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+
+	trims := make([][4]float64, len(slots))
+	contents := make([]string, len(slots))
+	from := make([]int, len(slots))
+	for i, s := range slots {
+		x, y, w, h := s.cell[0], s.cell[1], s.cell[2], s.cell[3]
+		trims[i] = [4]float64{x, y, x + w, y + h}
+		if border {
+			contents[i] = fmt.Sprintf("0.5 w %s %s %s %s re S", pdfNumber(x+0.25), pdfNumber(y+0.25), pdfNumber(w-0.5), pdfNumber(h-0.5))
+		}
+		from[i] = s.page
+	}
+	if err := writeSheets(cellsPath, sheetW, sheetH, trims, contents); err != nil {
+		return nil, err
+	}
+	if err := writeSheets(sheetsPath, sheetW, sheetH, nil, make([]string, sheets)); err != nil {
+		return nil, err
+	}
+
+	if err := m.runQPDF(cellsPath, "--overlay", inputPath, "--to=1-z", "--from="+joinPages(from), "--", placedPath); err != nil {
+		return nil, fmt.Errorf("failed to place pages: %w", err)
+	}
+
+	ids, err := readPageObjects(ctx, placedPath)
+	if err != nil {
+		return nil, err
+	}
+	header, objs, err := readQPDFObjects(ctx, placedPath, ids...)
+	if err != nil {
+		return nil, err
+	}
+	update := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		key := "obj:" + id + " 0 R"
+		page := make(map[string]json.RawMessage)
+		for k, v := range objs[key].Value {
+			page[k] = v
+		}
+		page["/TrimBox"] = page["/MediaBox"]
+		update[key] = qpdfObject{Value: page}
+	}
+	if err := updateQPDFObjects(ctx, workDir, placedPath, widenedPath, header, update); err != nil {
+		return nil, err
+	}
+
+	// The n-th slot of every sheet goes on in the n-th overlay
+	var to, slotPages [][]int
+	position := make(map[int]int)
+	for i, s := range slots {
+		n := position[s.sheet]
+		position[s.sheet]++
+		if n == len(to) {
+			to, slotPages = append(to, nil), append(slotPages, nil)
+		}
+		to[n] = append(to[n], s.sheet)
+		slotPages[n] = append(slotPages[n], i+1)
+	}
+	args := []string{sheetsPath}
+	for n := range to {
+		args = append(args, "--overlay", widenedPath, "--to="+joinPages(to[n]), "--from="+joinPages(slotPages[n]), "--")
+	}
+	args = append(args, outputPath)
+	if err := m.runQPDF(args...); err != nil {
+		return nil, fmt.Errorf("failed to assemble sheets: %w", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// writeSheets writes a PDF of empty sheets with optional TrimBoxes and
+// content, one page per entry of contents
+func writeSheets(path string, width, height float64, trims [][4]float64, contents []string) error {
+	w := newPDFWriter()
+	catalog := w.reserve()
+	pagesObj := w.reserve()
+
+	var kids bytes.Buffer
+	for i, content := range contents {
+		trim := ""
+		if trims != nil {
+			t := trims[i]
+			trim = fmt.Sprintf(" /TrimBox [%s %s %s %s]", pdfNumber(t[0]), pdfNumber(t[1]), pdfNumber(t[2]), pdfNumber(t[3]))
+		}
+		contentObj := w.writeStream("", []byte(content), false)
+		page := w.reserve()
+		w.writeObject(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s]%s /Resources << >> /Contents %d 0 R >>",
+			pagesObj, pdfNumber(width), pdfNumber(height), trim, contentObj))
+		fmt.Fprintf(&kids, "%d 0 R ", page)
+	}
+	w.writeObject(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(contents)))
+	w.writeObject(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+
+	if err := os.WriteFile(path, w.finish(catalog), 0644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return nil
+}
+
+// Resize scales the selected pages to fit a paper size (A4 by default),
+// centered. Without an orientation each page keeps its own.
+func (m *PDFManipulator) Resize(ctx context.Context, pdf []byte, pageRange string, opts *models.LayoutOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &models.LayoutOptions{}
+	}
+	size, sized, err := layoutSize(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !sized {
+		dims := models.PageA4.GetDimensions()
+		size = pageBox{Width: dims.Width * 72, Height: dims.Height * 72}
+	}
+	if opts.Orientation != "" && opts.Orientation != models.Portrait && opts.Orientation != models.Landscape {
+		return nil, fmt.Errorf("invalid orientation %q", opts.Orientation)
+	}
+
+	return m.editPages(ctx, pdf, pageRange, "resize-*", func(t *pageTree, key string, edit func(string) map[string]json.RawMessage) error {
+		box, rotate, ok := t.geometry(key)
+		if !ok {
+			return fmt.Errorf("page has no MediaBox")
+		}
+		bw, bh := box[2]-box[0], box[3]-box[1]
+
+		var w, h float64
+		if opts.Orientation == "" {
+			landscape := (bw > bh) != (rotate%180 != 0)
+			w, h = orient(size, models.Portrait)
+			if landscape {
+				w, h = h, w
+			}
+		} else {
+			w, h = orient(size, opts.Orientation)
+		}
+		// Sizes are as displayed; the page is rotated after its content
+		if rotate%180 != 0 {
+			w, h = h, w
+		}
+
+		s := math.Min(w/bw, h/bh)
+		tx, ty := (w-bw*s)/2-box[0]*s, (h-bh*s)/2-box[1]*s
+		transform := func(r [4]float64) json.RawMessage {
+			return qpdfRectValue([4]float64{r[0]*s + tx, r[1]*s + ty, r[2]*s + tx, r[3]*s + ty})
+		}
+
+		page := edit(key)
+		contents, err := t.contents(ctx, page["/Contents"])
+		if err != nil {
+			return err
+		}
+		pre := t.addStream(fmt.Sprintf("q %s 0 0 %s %s %s cm\n", pdfNumber(s), pdfNumber(s), pdfNumber(tx), pdfNumber(ty)))
+		post := t.addStream("\nQ\n")
+		page["/Contents"] = qpdfArray(append(append([]json.RawMessage{pre}, contents...), post))
+		page["/MediaBox"] = qpdfRectValue([4]float64{0, 0, w, h})
+		delete(page, "/CropBox")
+		if t.inherited(key, "/CropBox") != nil {
+			// A crop box from the page tree would still apply
+			page["/CropBox"] = page["/MediaBox"]
+		}
+		for _, k := range []string{"/TrimBox", "/BleedBox", "/ArtBox"} {
+			if r, ok := qpdfRect(page[k]); ok {
+				page[k] = transform(r)
+			}
+		}
+
+		// Annotations keep their place on the content
+		annots, err := t.array(ctx, page["/Annots"])
+		if err != nil {
+			return err
+		}
+		changed := false
+		for i, a := range annots {
+			if ref, ok := qpdfRef(a); ok {
+				annot := edit(ref + " 0 R")
+				if r, ok := qpdfRect(annot["/Rect"]); ok {
+					annot["/Rect"] = transform(r)
+				}
+				continue
+			}
+			var annot map[string]json.RawMessage
+			if json.Unmarshal(a, &annot) == nil {
+				if r, ok := qpdfRect(annot["/Rect"]); ok {
+					annot["/Rect"] = transform(r)
+					annots[i], _ = json.Marshal(annot)
+					changed = true
+				}
+			}
+		}
+		if changed {
+			page["/Annots"] = qpdfArray(annots)
+		}
+		return nil
+	})
+}
+
+// Crop sets the crop box (or the trim, bleed or art box) of the selected
+// pages to an explicit rectangle or to the current box less margins
+func (m *PDFManipulator) Crop(ctx context.Context, pdf []byte, pageRange string, opts *models.LayoutOptions) ([]byte, error) {
+	if opts == nil || (opts.Rect == nil && opts.Margins == nil) {
+		return nil, fmt.Errorf("rect or margins is required for crop")
+	}
+	names := opts.Boxes
+	if len(names) == 0 {
+		names = []string{"crop"}
+	}
+	keys := make([]string, len(names))
+	for i, name := range names {
+		key, ok := pageBoxKeys[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown box %q (use crop, trim, bleed or art)", name)
+		}
+		keys[i] = key
+	}
+
+	return m.editPages(ctx, pdf, pageRange, "crop-*", func(t *pageTree, key string, edit func(string) map[string]json.RawMessage) error {
+		box, rotate, ok := t.geometry(key)
+		if !ok {
+			return fmt.Errorf("page has no MediaBox")
+		}
+
+		var r [4]float64
+		if opts.Rect != nil {
+			x, y, w, h := opts.Rect[0], opts.Rect[1], opts.Rect[2], opts.Rect[3]
+			r = [4]float64{x, y, x + w, y + h}
+		} else {
+			// Map the edges as displayed to the unrotated page
+			mg := opts.Margins
+			left, top, right, bottom := mg.Left, mg.Top, mg.Right, mg.Bottom
+			switch rotate {
+			case 90:
+				left, top, right, bottom = mg.Top, mg.Right, mg.Bottom, mg.Left
+			case 180:
+				left, top, right, bottom = mg.Right, mg.Bottom, mg.Left, mg.Top
+			case 270:
+				left, top, right, bottom = mg.Bottom, mg.Left, mg.Top, mg.Right
+			}
+			r = [4]float64{box[0] + left, box[1] + bottom, box[2] - right, box[3] - top}
+		}
+		if r[2] <= r[0] || r[3] <= r[1] {
+			return fmt.Errorf("crop leaves no area")
+		}
+
+		page := edit(key)
+		for _, k := range keys {
+			page[k] = qpdfRectValue(r)
+		}
+		return nil
+	})
+}
+
+// editPages runs fn on the selected pages of a PDF through qpdf JSON
+func (m *PDFManipulator) editPages(ctx context.Context, pdf []byte, pageRange, pattern string, fn pageEditor) ([]byte, error) {
+	workDir, err := os.MkdirTemp(m.tempDir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+	if err := editPageTree(ctx, workDir, inputPath, outputPath, pageRange, fn); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(outputPath)
+}
+
+// pageEditor changes the page with the given object key. edit returns the
+// dictionary of an object to change in place.
+type pageEditor func(t *pageTree, key string, edit func(string) map[string]json.RawMessage) error
+
+// editPageTree runs fn on the selected pages of a file. Objects are
+// replaced whole, so edits collect on copies of their values.
+func editPageTree(ctx context.Context, workDir, inputPath, outputPath, pageRange string, fn pageEditor) error {
+	t, err := readPageTree(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	pages, err := selectPages(pageRange, len(t.pages))
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]map[string]json.RawMessage)
+	edit := func(key string) map[string]json.RawMessage {
+		key = strings.TrimPrefix(key, "obj:")
+		if d, ok := changed[key]; ok {
+			return d
+		}
+		d := make(map[string]json.RawMessage)
+		for k, v := range t.objs["obj:"+key].Value {
+			d[k] = v
+		}
+		changed[key] = d
+		return d
+	}
+	for _, p := range pages {
+		if err := fn(t, t.pages[p-1], edit); err != nil {
+			return fmt.Errorf("page %d: %w", p, err)
+		}
+	}
+
+	update := make(map[string]interface{}, len(changed)+len(t.added))
+	for ref, value := range changed {
+		update["obj:"+ref] = qpdfObject{Value: value}
+	}
+	for key, obj := range t.added {
+		update[key] = obj
+	}
+	return updateQPDFObjects(ctx, workDir, inputPath, outputPath, t.header, update)
+}
+
+// pageTree is a document's objects (without stream data) and its pages
+type pageTree struct {
+	path   string
+	header json.RawMessage
+	objs   map[string]qpdfObject
+	pages  []string // Object keys ("obj:N 0 R") in page order
+
+	nextID int
+	added  map[string]qpdfObject // New objects for the update
+}
+
+// readPageTree reads the page list and all objects without stream data
+func readPageTree(ctx context.Context, pdfPath string) (*pageTree, error) {
+	cmd := exec.CommandContext(ctx, "qpdf", "--json=2", "--json-key=pages", "--json-key=qpdf",
+		"--json-stream-data=none", pdfPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pages: %w - %s", err, stderr.String())
+	}
+
+	var doc struct {
+		Pages []struct {
+			Object string `json:"object"`
+		} `json:"pages"`
+		QPDF []json.RawMessage `json:"qpdf"`
+	}
+	if err := json.Unmarshal(output, &doc); err != nil || len(doc.QPDF) != 2 {
+		return nil, fmt.Errorf("unexpected qpdf JSON output")
+	}
+	t := &pageTree{path: pdfPath, header: doc.QPDF[0], added: make(map[string]qpdfObject)}
+	if err := json.Unmarshal(doc.QPDF[1], &t.objs); err != nil {
+		return nil, fmt.Errorf("unexpected qpdf JSON objects: %w", err)
+	}
+	for _, p := range doc.Pages {
+		t.pages = append(t.pages, "obj:"+p.Object)
+	}
+	t.nextID = maxObjectID(t.header)
+	return t, nil
+}
+
+// inherited returns a page attribute, looking up the page tree for the
+// inheritable ones (MediaBox, CropBox, Rotate, Resources)
+func (t *pageTree) inherited(key, attr string) json.RawMessage {
+	obj := t.objs[key].Value
+	for depth := 0; obj != nil && depth < 32; depth++ {
+		if v, ok := obj[attr]; ok {
+			return v
+		}
+		parent, ok := qpdfRefString(obj["/Parent"])
+		if !ok {
+			break
+		}
+		obj = t.objs["obj:"+parent].Value
+	}
+	return nil
+}
+
+// geometry returns the visible box (CropBox, else MediaBox) of a page as
+// x0, y0, x1, y1 and its rotation in degrees, 0 to 270
+func (t *pageTree) geometry(key string) ([4]float64, int, bool) {
+	box, ok := qpdfRect(t.inherited(key, "/CropBox"))
+	if !ok {
+		if box, ok = qpdfRect(t.inherited(key, "/MediaBox")); !ok {
+			return box, 0, false
+		}
+	}
+	var rotate int
+	json.Unmarshal(t.inherited(key, "/Rotate"), &rotate)
+	return box, (rotate%360 + 360) % 360, true
+}
+
+// contents returns the content stream references of a page
+func (t *pageTree) contents(ctx context.Context, raw json.RawMessage) ([]json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if ref, ok := qpdfRefString(raw); ok && t.objs["obj:"+ref].Stream != nil {
+		return []json.RawMessage{raw}, nil
+	}
+	return t.array(ctx, raw)
+}
+
+// array returns the items of a direct or indirect array
+func (t *pageTree) array(ctx context.Context, raw json.RawMessage) ([]json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if id, ok := qpdfRef(raw); ok {
+		value, err := readQPDFValue(ctx, t.path, id)
+		if err != nil {
+			return nil, err
+		}
+		raw = value
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("unexpected array value")
+	}
+	return items, nil
+}
+
+// addStream adds a content stream object and returns a reference to it
+func (t *pageTree) addStream(content string) json.RawMessage {
+	t.nextID++
+	id := fmt.Sprint(t.nextID)
+	t.added["obj:"+id+" 0 R"] = qpdfObject{Stream: &qpdfStream{
+		Dict: map[string]json.RawMessage{},
+		Data: base64.StdEncoding.EncodeToString([]byte(content)),
+	}}
+	return qpdfRefValue(id)
+}
+
+// qpdfRect reads a rectangle as x0, y0, x1, y1 with x0 <= x1, y0 <= y1
+func qpdfRect(raw json.RawMessage) ([4]float64, bool) {
+	var r []float64
+	if json.Unmarshal(raw, &r) != nil || len(r) != 4 {
+		return [4]float64{}, false
+	}
+	return [4]float64{math.Min(r[0], r[2]), math.Min(r[1], r[3]), math.Max(r[0], r[2]), math.Max(r[1], r[3])}, true
+}
+
+func qpdfRectValue(r [4]float64) json.RawMessage {
+	b, _ := json.Marshal([]json.Number{
+		json.Number(pdfNumber(r[0])), json.Number(pdfNumber(r[1])),
+		json.Number(pdfNumber(r[2])), json.Number(pdfNumber(r[3])),
+	})
+	return b
+}
+
+// layoutSize returns the requested paper size in points, portrait, and
+// false when none was requested
+func layoutSize(opts *models.LayoutOptions) (pageBox, bool, error) {
+	if opts.PageSize == "" {
+		return pageBox{}, false, nil
+	}
+	var dims models.PageDimensions
+	switch opts.PageSize {
+	case models.PageA4, models.PageA3, models.PageLetter, models.PageLegal, models.PageTabloid:
+		dims = opts.PageSize.GetDimensions()
+	case models.PageCustom:
+		if opts.CustomDimensions == nil || opts.CustomDimensions.Width <= 0 || opts.CustomDimensions.Height <= 0 {
+			return pageBox{}, false, fmt.Errorf("custom_dimensions are required for a Custom page size")
+		}
+		dims = *opts.CustomDimensions
+	default:
+		return pageBox{}, false, fmt.Errorf("unknown page size %q", opts.PageSize)
+	}
+	w, h := math.Min(dims.Width, dims.Height), math.Max(dims.Width, dims.Height)
+	return pageBox{Width: w * 72, Height: h * 72}, true, nil
+}
+
+// orient returns a size turned to an orientation
+func orient(size pageBox, o models.Orientation) (float64, float64) {
+	w, h := size.Width, size.Height
+	if (o == models.Landscape) != (w > h) {
+		w, h = h, w
+	}
+	return w, h
+}
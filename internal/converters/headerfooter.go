@@ -0,0 +1,82 @@
+package converters
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// headerFooterPlaceholders maps user placeholders to the span classes
+// Chrome fills in when rendering header and footer templates
+var headerFooterPlaceholders = map[string]string{
+	"{page}":  `<span class="pageNumber"></span>`,
+	"{total}": `<span class="totalPages"></span>`,
+	"{date}":  `<span class="date"></span>`,
+	"{title}": `<span class="title"></span>`,
+	"{url}":   `<span class="url"></span>`,
+}
+
+// emptyTemplate suppresses Chrome's default header/footer (date, title, url)
+const emptyTemplate = `<span></span>`
+
+// HasHeaderFooter reports whether any header or footer content is configured
+func HasHeaderFooter(hf *models.HeaderFooter) bool {
+	if hf == nil {
+		return false
+	}
+	return hf.HeaderHTML != "" || hf.FooterHTML != "" ||
+		hf.HeaderLeft != "" || hf.HeaderCenter != "" || hf.HeaderRight != "" ||
+		hf.FooterLeft != "" || hf.FooterCenter != "" || hf.FooterRight != ""
+}
+
+// buildHeaderFooterTemplates returns the Chrome header and footer templates
+func buildHeaderFooterTemplates(hf *models.HeaderFooter, margins *models.Margins) (string, string) {
+	fontSize := hf.FontSize
+	if fontSize <= 0 {
+		fontSize = 9
+	}
+
+	// Chrome renders templates edge-to-edge, so pad them to line up with the body
+	padLeft, padRight := 0.4, 0.4
+	if margins != nil {
+		padLeft, padRight = margins.Left, margins.Right
+	}
+
+	header := hf.HeaderHTML
+	if header == "" {
+		header = buildTemplateRow(hf.HeaderLeft, hf.HeaderCenter, hf.HeaderRight, fontSize, padLeft, padRight)
+	}
+
+	footer := hf.FooterHTML
+	if footer == "" {
+		footer = buildTemplateRow(hf.FooterLeft, hf.FooterCenter, hf.FooterRight, fontSize, padLeft, padRight)
+	}
+
+	return header, footer
+}
+
+// buildTemplateRow lays out left/center/right cells in a single row
+func buildTemplateRow(left, center, right string, fontSize, padLeft, padRight float64) string {
+	if left == "" && center == "" && right == "" {
+		return emptyTemplate
+	}
+
+	cell := `<div style="flex:1;text-align:%s;overflow:hidden;white-space:nowrap;">%s</div>`
+	return fmt.Sprintf(`<div style="width:100%%;display:flex;font-family:Arial,sans-serif;font-size:%.1fpx;color:#555;padding:0 %.2fin 0 %.2fin;box-sizing:border-box;-webkit-print-color-adjust:exact;">`,
+		fontSize, padLeft, padRight) +
+		fmt.Sprintf(cell, "left", expandPlaceholders(left)) +
+		fmt.Sprintf(cell, "center", expandPlaceholders(center)) +
+		fmt.Sprintf(cell, "right", expandPlaceholders(right)) +
+		`</div>`
+}
+
+// expandPlaceholders escapes user text and substitutes Chrome placeholders
+func expandPlaceholders(text string) string {
+	text = html.EscapeString(text)
+	for placeholder, span := range headerFooterPlaceholders {
+		text = strings.ReplaceAll(text, placeholder, span)
+	}
+	return text
+}
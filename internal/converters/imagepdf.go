@@ -0,0 +1,393 @@
+package converters
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"pdf-forge/internal/models"
+)
+
+// pointsPerPixel maps image pixels to PDF points at the CSS 96 dpi,
+// so native output matches what Chrome renders
+const pointsPerPixel = 72.0 / 96.0
+
+// gridGapPoints is the space between grid cells (0.15in, as in the Chrome layout)
+const gridGapPoints = 10.8
+
+// imageSlot places one image inside a box on a page
+type imageSlot struct {
+	image         *imageInfo
+	x, y, w, h    float64 // Box in points, origin bottom-left
+	resourceIndex int     // Resource name index, /Im<n>
+}
+
+// imagePage is one output page and the images drawn on it
+type imagePage struct {
+	width, height float64
+	slots         []*imageSlot
+}
+
+// canWriteImagesNatively reports whether writeImagesPDF can produce the
+// requested output without Chrome
+func canWriteImagesNatively(images []*imageInfo, opts *models.PDFOptions) bool {
+	for _, img := range images {
+		switch img.Format {
+		case "jpeg", "png", "gif":
+		default:
+			return false
+		}
+	}
+	if opts == nil {
+		return true
+	}
+	// Captions and page decorations need a layout engine
+	if opts.Images != nil && len(opts.Images.Captions) > 0 {
+		return false
+	}
+	return !HasHeaderFooter(opts.HeaderFooter) && !opts.Grayscale
+}
+
+// writeImagesPDF writes JPEG, PNG and GIF images to a PDF without a
+// browser. JPEGs are embedded as-is (DCT passthrough); other formats are
+// stored losslessly with Flate, with alpha as a soft mask.
+func writeImagesPDF(images []*imageInfo, opts *models.PDFOptions) ([]byte, error) {
+	imgOpts := &models.ImageOptions{}
+	if opts != nil && opts.Images != nil {
+		imgOpts = opts.Images
+	}
+
+	pages := layoutImagePages(images, imgOpts, opts)
+	w := newPDFWriter()
+
+	catalog := w.reserve()
+	pagesObj := w.reserve()
+
+	// Each distinct image is embedded once
+	xObjects := make(map[*imageInfo]int)
+	pageRefs := make([]int, 0, len(pages))
+	for _, p := range pages {
+		var content bytes.Buffer
+		var resources bytes.Buffer
+		for i, slot := range p.slots {
+			obj, ok := xObjects[slot.image]
+			if !ok {
+				var err error
+				if obj, err = w.writeImage(slot.image); err != nil {
+					return nil, err
+				}
+				xObjects[slot.image] = obj
+			}
+			slot.resourceIndex = i
+			fmt.Fprintf(&resources, "/Im%d %d 0 R ", i, obj)
+			writeImagePlacement(&content, slot, imgOpts.Fit)
+		}
+
+		contentObj := w.writeStream("", content.Bytes(), true)
+		pageObj := w.reserve()
+		w.writeObject(pageObj, fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << %s>> >> /Contents %d 0 R >>",
+			pagesObj, pdfNumber(p.width), pdfNumber(p.height), resources.String(), contentObj))
+		pageRefs = append(pageRefs, pageObj)
+	}
+
+	var kids bytes.Buffer
+	for _, ref := range pageRefs {
+		fmt.Fprintf(&kids, "%d 0 R ", ref)
+	}
+	w.writeObject(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(pageRefs)))
+	w.writeObject(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+
+	return w.finish(catalog), nil
+}
+
+// layoutImagePages computes page sizes and image boxes, mirroring the
+// Chrome layout in buildImagesHTML
+func layoutImagePages(images []*imageInfo, imgOpts *models.ImageOptions, opts *models.PDFOptions) []*imagePage {
+	dims := models.PageA4.GetDimensions()
+	margins := models.Margins{}
+	if opts != nil {
+		dims = opts.PageDimensions()
+		if opts.Margins != nil {
+			margins = *opts.Margins
+		}
+	}
+	pageW, pageH := dims.Width*72, dims.Height*72
+	left, bottom := margins.Left*72, margins.Bottom*72
+	contentW := pageW - left - margins.Right*72
+	contentH := pageH - bottom - margins.Top*72
+
+	var pages []*imagePage
+
+	if imgOpts.Layout == ImageLayoutGrid {
+		perPage := imgOpts.PerPage
+		if perPage <= 0 {
+			perPage = 4
+		}
+		columns := imgOpts.Columns
+		if columns <= 0 {
+			columns = int(math.Ceil(math.Sqrt(float64(perPage))))
+		}
+		rows := int(math.Ceil(float64(perPage) / float64(columns)))
+		cellW := (contentW - gridGapPoints*float64(columns-1)) / float64(columns)
+		cellH := (contentH - gridGapPoints*float64(rows-1)) / float64(rows)
+
+		for start := 0; start < len(images); start += perPage {
+			p := &imagePage{width: pageW, height: pageH}
+			for i := start; i < start+perPage && i < len(images); i++ {
+				col, row := (i-start)%columns, (i-start)/columns
+				p.slots = append(p.slots, &imageSlot{
+					image: images[i],
+					x:     left + float64(col)*(cellW+gridGapPoints),
+					y:     bottom + contentH - float64(row+1)*cellH - float64(row)*gridGapPoints,
+					w:     cellW,
+					h:     cellH,
+				})
+			}
+			pages = append(pages, p)
+		}
+		return pages
+	}
+
+	for _, img := range images {
+		if imgOpts.PageFitImage {
+			w, h := float64(img.Width)*pointsPerPixel, float64(img.Height)*pointsPerPixel
+			pages = append(pages, &imagePage{width: w, height: h, slots: []*imageSlot{{image: img, w: w, h: h}}})
+			continue
+		}
+		pages = append(pages, &imagePage{
+			width:  pageW,
+			height: pageH,
+			slots:  []*imageSlot{{image: img, x: left, y: bottom, w: contentW, h: contentH}},
+		})
+	}
+	return pages
+}
+
+// writeImagePlacement draws an image into its box using the fit mode and
+// the EXIF orientation
+func writeImagePlacement(b *bytes.Buffer, slot *imageSlot, fit string) {
+	img := slot.image
+	natW, natH := float64(img.Width)*pointsPerPixel, float64(img.Height)*pointsPerPixel
+
+	drawW, drawH := slot.w, slot.h
+	switch fit {
+	case ImageFitStretch:
+	case ImageFitActual:
+		drawW, drawH = natW, natH
+	case ImageFitCover:
+		scale := math.Max(slot.w/natW, slot.h/natH)
+		drawW, drawH = natW*scale, natH*scale
+	default: // contain
+		scale := math.Min(slot.w/natW, slot.h/natH)
+		drawW, drawH = natW*scale, natH*scale
+	}
+	x := slot.x + (slot.w-drawW)/2
+	y := slot.y + (slot.h-drawH)/2
+
+	b.WriteString("q\n")
+	// Clip to the box so cover and actual don't spill over the page or neighbours
+	fmt.Fprintf(b, "%s %s %s %s re W n\n", pdfNumber(slot.x), pdfNumber(slot.y), pdfNumber(slot.w), pdfNumber(slot.h))
+	fmt.Fprintf(b, "%s 0 0 %s %s %s cm\n", pdfNumber(drawW), pdfNumber(drawH), pdfNumber(x), pdfNumber(y))
+	if m := exifMatrix(img.Orientation); m != "" {
+		b.WriteString(m + " cm\n")
+	}
+	fmt.Fprintf(b, "/Im%d Do\nQ\n", slot.resourceIndex)
+}
+
+// exifMatrix maps the stored image's unit square onto the displayed one
+// for EXIF orientations 2-8
+func exifMatrix(orientation int) string {
+	switch orientation {
+	case 2:
+		return "-1 0 0 1 1 0"
+	case 3:
+		return "-1 0 0 -1 1 1"
+	case 4:
+		return "1 0 0 -1 0 1"
+	case 5:
+		return "0 -1 -1 0 1 1"
+	case 6:
+		return "0 -1 1 0 0 1"
+	case 7:
+		return "0 1 1 0 0 0"
+	case 8:
+		return "0 1 -1 0 1 0"
+	}
+	return ""
+}
+
+// writeImage embeds an image XObject and returns its object number
+func (w *pdfWriter) writeImage(img *imageInfo) (int, error) {
+	if img.Format == "jpeg" {
+		return w.writeJPEG(img)
+	}
+
+	decoded, _, err := DecodeImage(img.Data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode %s image: %w", img.Format, err)
+	}
+	return w.writeRaster(decoded), nil
+}
+
+// writeJPEG embeds the JPEG bytes unchanged with DCTDecode
+func (w *pdfWriter) writeJPEG(img *imageInfo) (int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read JPEG header: %w", err)
+	}
+
+	colorSpace, extra := "/DeviceRGB", ""
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		colorSpace = "/DeviceGray"
+	case color.CMYKModel:
+		// Adobe CMYK JPEGs store inverted values
+		colorSpace, extra = "/DeviceCMYK", " /Decode [1 0 1 0 1 0 1 0]"
+	}
+
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode%s",
+		cfg.Width, cfg.Height, colorSpace, extra)
+	return w.writeStream(dict, img.Data, false), nil
+}
+
+// writeRaster stores decoded pixels losslessly with Flate, keeping 16-bit
+// depth and writing alpha as a soft mask when the image uses it
+func (w *pdfWriter) writeRaster(img image.Image) int {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	gray, deep := false, false
+	switch img.ColorModel() {
+	case color.GrayModel:
+		gray = true
+	case color.Gray16Model:
+		gray, deep = true, true
+	case color.RGBA64Model, color.NRGBA64Model:
+		deep = true
+	}
+
+	bpc, channels := 8, 3
+	if deep {
+		bpc = 16
+	}
+	if gray {
+		channels = 1
+	}
+	sampleBytes := bpc / 8
+
+	pixels := make([]byte, 0, width*height*channels*sampleBytes)
+	alpha := make([]byte, 0, width*height*sampleBytes)
+	hasAlpha := false
+
+	put := func(buf []byte, v uint16) []byte {
+		if deep {
+			return append(buf, byte(v>>8), byte(v))
+		}
+		return append(buf, byte(v>>8))
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			if gray {
+				pixels = put(pixels, c.R)
+			} else {
+				pixels = put(pixels, c.R)
+				pixels = put(pixels, c.G)
+				pixels = put(pixels, c.B)
+			}
+			alpha = put(alpha, c.A)
+			if c.A != 0xffff {
+				hasAlpha = true
+			}
+		}
+	}
+
+	colorSpace := "/DeviceRGB"
+	if gray {
+		colorSpace = "/DeviceGray"
+	}
+
+	smask := ""
+	if hasAlpha {
+		maskObj := w.writeStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent %d",
+			width, height, bpc), alpha, true)
+		smask = fmt.Sprintf(" /SMask %d 0 R", maskObj)
+	}
+
+	return w.writeStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent %d%s",
+		width, height, colorSpace, bpc, smask), pixels, true)
+}
+
+// pdfWriter assembles a PDF file object by object
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int // Byte offset per object number, index 0 unused
+}
+
+func newPDFWriter() *pdfWriter {
+	w := &pdfWriter{offsets: []int{0}}
+	// Binary comment marks the file as 8-bit for transfer tools
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// reserve allocates an object number to be written later
+func (w *pdfWriter) reserve() int {
+	w.offsets = append(w.offsets, -1)
+	return len(w.offsets) - 1
+}
+
+func (w *pdfWriter) writeObject(num int, body string) {
+	w.offsets[num] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+// writeStream writes a stream object, Flate-compressing data if asked
+func (w *pdfWriter) writeStream(dict string, data []byte, compress bool) int {
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(data)
+		zw.Close()
+		data = z.Bytes()
+		dict += " /Filter /FlateDecode"
+	}
+
+	num := w.reserve()
+	w.offsets[num] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", num, dict, len(data))
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return num
+}
+
+// finish writes the cross-reference table and trailer
+func (w *pdfWriter) finish(root int) []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets))
+	for _, off := range w.offsets[1:] {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets), root, xref)
+	return w.buf.Bytes()
+}
+
+// pdfNumber formats a coordinate without exponent notation
+func pdfNumber(v float64) string {
+	s := fmt.Sprintf("%.4f", v)
+	for len(s) > 1 && s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
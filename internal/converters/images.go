@@ -0,0 +1,250 @@
+package converters
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif" // Register GIF decoder for DecodeConfig
+	"math"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// Image layout and fit modes
+const (
+	ImageLayoutPage = "page" // One image per page
+	ImageLayoutGrid = "grid" // N images per page
+
+	ImageFitContain = "contain"
+	ImageFitCover   = "cover"
+	ImageFitStretch = "stretch"
+	ImageFitActual  = "actual"
+)
+
+// imageInfo describes a decoded input image
+type imageInfo struct {
+	Data        []byte
+	Format      string // jpeg, png, gif; empty when Go can't decode it
+	Width       int    // Pixel size after applying EXIF orientation
+	Height      int
+	Orientation int // EXIF orientation 1-8, 1 when absent
+}
+
+// decodeImageInput accepts raw base64 or a data: URI
+func decodeImageInput(input string) ([]byte, error) {
+	if strings.HasPrefix(input, "data:") {
+		if idx := strings.Index(input, ","); idx >= 0 {
+			input = input[idx+1:]
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(input))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 image: %w", err)
+	}
+	return data, nil
+}
+
+// inspectImage reads the size, format and EXIF orientation of an image
+func inspectImage(data []byte) *imageInfo {
+	info := &imageInfo{Data: data, Orientation: 1}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// Chrome may still render formats Go can't decode (e.g. WebP)
+		return info
+	}
+	info.Format = format
+	info.Width, info.Height = cfg.Width, cfg.Height
+
+	if format == "jpeg" {
+		info.Orientation = jpegOrientation(data)
+		// Orientations 5-8 rotate by 90 degrees, swapping the displayed size
+		if info.Orientation >= 5 && info.Orientation <= 8 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	return info
+}
+
+// mimeType returns the data URI media type
+func (i *imageInfo) mimeType() string {
+	if i.Format == "" {
+		return "image"
+	}
+	return "image/" + i.Format
+}
+
+// jpegOrientation extracts the EXIF orientation tag from a JPEG, 1 if absent
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || size < 2 || pos+2+size > len(data) {
+			// Start of scan or truncated segment: no EXIF ahead
+			return 1
+		}
+		segment := data[pos+4 : pos+2+size]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation reads tag 0x0112 from the first IFD of a TIFF header
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8:]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// buildImagesHTML lays out images one per page or in a grid. When the page
+// should match the image, each image gets its own named @page size.
+func buildImagesHTML(images []*imageInfo, imgOpts *models.ImageOptions, opts *models.PDFOptions) string {
+	layout := imgOpts.Layout
+	if layout == "" {
+		layout = ImageLayoutPage
+	}
+
+	objectFit := "contain"
+	switch imgOpts.Fit {
+	case ImageFitCover:
+		objectFit = "cover"
+	case ImageFitStretch:
+		objectFit = "fill"
+	case ImageFitActual:
+		objectFit = "none"
+	}
+
+	// Content box of one page in inches
+	dims := models.PageA4.GetDimensions()
+	margins := models.Margins{}
+	if opts != nil {
+		dims = opts.PageDimensions()
+		if opts.Margins != nil {
+			margins = *opts.Margins
+		}
+	}
+	contentW := dims.Width - margins.Left - margins.Right
+	contentH := dims.Height - margins.Top - margins.Bottom
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"><style>
+html, body { margin: 0; padding: 0; }
+img { image-orientation: from-image; display: block; }
+.sheet { box-sizing: border-box; overflow: hidden; break-after: page; }
+.sheet:last-child { break-after: auto; }
+.cell { display: flex; flex-direction: column; min-width: 0; min-height: 0; overflow: hidden; }
+.frame { flex: 1; min-height: 0; position: relative; }
+.frame img { position: absolute; inset: 0; width: 100%; height: 100%; }
+figcaption { font-family: Arial, sans-serif; font-size: 10pt; color: #333; text-align: center; padding: 4px 0; }
+`)
+	fmt.Fprintf(&b, ".frame img { object-fit: %s; }\n", objectFit)
+
+	if layout == ImageLayoutGrid {
+		perPage := imgOpts.PerPage
+		if perPage <= 0 {
+			perPage = 4
+		}
+		columns := imgOpts.Columns
+		if columns <= 0 {
+			columns = int(math.Ceil(math.Sqrt(float64(perPage))))
+		}
+		rows := int(math.Ceil(float64(perPage) / float64(columns)))
+
+		fmt.Fprintf(&b, `.sheet { width: %.3fin; height: %.3fin; display: grid; gap: 0.15in;
+grid-template-columns: repeat(%d, 1fr); grid-template-rows: repeat(%d, 1fr); }
+</style></head><body>`, contentW, contentH, columns, rows)
+
+		for start := 0; start < len(images); start += perPage {
+			b.WriteString(`<div class="sheet">`)
+			for i := start; i < start+perPage && i < len(images); i++ {
+				writeImageCell(&b, images[i], caption(imgOpts, i))
+			}
+			b.WriteString(`</div>`)
+		}
+		b.WriteString("</body></html>")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, ".sheet { width: %.3fin; height: %.3fin; }\n", contentW, contentH)
+	if imgOpts.PageFitImage {
+		// Named pages let every sheet carry its own paper size
+		for i, img := range images {
+			if img.Width == 0 || img.Height == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "@page img%d { size: %dpx %dpx; margin: 0; }\n", i, img.Width, img.Height)
+			fmt.Fprintf(&b, ".sheet-%d { page: img%d; width: %dpx; height: %dpx; }\n", i, i, img.Width, img.Height)
+		}
+	}
+	b.WriteString("</style></head><body>")
+
+	for i, img := range images {
+		fmt.Fprintf(&b, `<div class="sheet sheet-%d">`, i)
+		writeImageCell(&b, img, caption(imgOpts, i))
+		b.WriteString(`</div>`)
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+func writeImageCell(b *strings.Builder, img *imageInfo, caption string) {
+	b.WriteString(`<figure class="cell" style="margin:0;height:100%;">`)
+	fmt.Fprintf(b, `<div class="frame"><img src="data:%s;base64,%s" /></div>`,
+		img.mimeType(), base64.StdEncoding.EncodeToString(img.Data))
+	if caption != "" {
+		fmt.Fprintf(b, "<figcaption>%s</figcaption>", html.EscapeString(caption))
+	}
+	b.WriteString(`</figure>`)
+}
+
+func caption(imgOpts *models.ImageOptions, i int) string {
+	if i < len(imgOpts.Captions) {
+		return imgOpts.Captions[i]
+	}
+	return ""
+}
@@ -0,0 +1,283 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// An incremental update appends changed and new objects after the original
+// bytes, leaving earlier revisions (and the signatures covering them) intact.
+
+// updateTarget describes the revision an incremental update extends
+type updateTarget struct {
+	header   json.RawMessage
+	trailer  map[string]json.RawMessage
+	rootID   string
+	catalog  map[string]json.RawMessage
+	prevXref int  // Offset of the last cross-reference section
+	xrefStm  bool // The last section is a cross-reference stream
+}
+
+// readUpdateTarget reads the trailer, catalog and last xref offset of a PDF
+func readUpdateTarget(ctx context.Context, pdfPath string, data []byte) (*updateTarget, error) {
+	_, objs, err := readQPDFObjects(ctx, pdfPath, "trailer")
+	if err != nil {
+		return nil, err
+	}
+	t := &updateTarget{trailer: objs["trailer"].Value}
+	if _, encrypted := t.trailer["/Encrypt"]; encrypted {
+		return nil, fmt.Errorf("encrypted PDFs cannot be updated; decrypt first")
+	}
+	var ok bool
+	if t.rootID, ok = qpdfRef(t.trailer["/Root"]); !ok {
+		return nil, fmt.Errorf("PDF has no document catalog")
+	}
+	t.header, objs, err = readQPDFObjects(ctx, pdfPath, t.rootID)
+	if err != nil {
+		return nil, err
+	}
+	if t.catalog = objs["obj:"+t.rootID+" 0 R"].Value; t.catalog == nil {
+		return nil, fmt.Errorf("PDF has no document catalog")
+	}
+
+	if t.prevXref, err = lastStartXref(data); err != nil {
+		return nil, err
+	}
+	t.xrefStm = !bytes.HasPrefix(bytes.TrimLeft(data[t.prevXref:], " \r\n\t"), []byte("xref"))
+	return t, nil
+}
+
+var startXrefPattern = regexp.MustCompile(`startxref\s+(\d+)`)
+
+// lastStartXref returns the offset recorded by the final startxref
+func lastStartXref(data []byte) (int, error) {
+	tail := data
+	if len(tail) > 2048 {
+		tail = tail[len(tail)-2048:]
+	}
+	matches := startXrefPattern.FindAllSubmatch(tail, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("PDF has no startxref")
+	}
+	offset, err := strconv.Atoi(string(matches[len(matches)-1][1]))
+	if err != nil || offset <= 0 || offset >= len(data) {
+		return 0, fmt.Errorf("PDF has an invalid startxref")
+	}
+	return offset, nil
+}
+
+// newUpdateWriter starts an incremental update of original, whose objects
+// are numbered up to maxID. Existing objects are only written if changed.
+func newUpdateWriter(original []byte, maxID int) *pdfWriter {
+	w := &pdfWriter{offsets: make([]int, maxID+1)}
+	for i := range w.offsets {
+		w.offsets[i] = -1
+	}
+	w.buf.Write(original)
+	if len(original) > 0 && original[len(original)-1] != '\n' {
+		w.buf.WriteByte('\n')
+	}
+	return w
+}
+
+// finishUpdate writes the cross-reference section for the objects written
+// since newUpdateWriter, chained to the previous revision with /Prev
+func (w *pdfWriter) finishUpdate(t *updateTarget) []byte {
+	extra := ""
+	for _, key := range []string{"/Root", "/Info", "/ID"} {
+		if v, ok := t.trailer[key]; ok {
+			extra += " " + key + " " + pdfValue(v)
+		}
+	}
+
+	if t.xrefStm {
+		// Updates of files with xref streams must use one as well
+		num := w.reserve()
+		w.offsets[num] = w.buf.Len()
+		var rows bytes.Buffer
+		var index []string
+		for _, run := range w.changedRuns() {
+			index = append(index, fmt.Sprintf("%d %d", run[0], run[1]))
+			for n := run[0]; n < run[0]+run[1]; n++ {
+				off := w.offsets[n]
+				rows.Write([]byte{1, byte(off >> 24), byte(off >> 16), byte(off >> 8), byte(off), 0, 0})
+			}
+		}
+		fmt.Fprintf(&w.buf, "%d 0 obj\n<< /Type /XRef /Size %d /Index [%s] /W [1 4 2] /Prev %d%s /Length %d >>\nstream\n",
+			num, len(w.offsets), strings.Join(index, " "), t.prevXref, extra, rows.Len())
+		w.buf.Write(rows.Bytes())
+		fmt.Fprintf(&w.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", w.offsets[num])
+		return w.buf.Bytes()
+	}
+
+	xref := w.buf.Len()
+	w.buf.WriteString("xref\n")
+	for _, run := range w.changedRuns() {
+		fmt.Fprintf(&w.buf, "%d %d\n", run[0], run[1])
+		for n := run[0]; n < run[0]+run[1]; n++ {
+			fmt.Fprintf(&w.buf, "%010d 00000 n \n", w.offsets[n])
+		}
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Prev %d%s >>\nstartxref\n%d\n%%%%EOF\n",
+		len(w.offsets), t.prevXref, extra, xref)
+	return w.buf.Bytes()
+}
+
+// changedRuns groups written object numbers into [first, count] runs
+func (w *pdfWriter) changedRuns() [][2]int {
+	var runs [][2]int
+	for n := 1; n < len(w.offsets); n++ {
+		if w.offsets[n] < 0 {
+			continue
+		}
+		if len(runs) > 0 && runs[len(runs)-1][0]+runs[len(runs)-1][1] == n {
+			runs[len(runs)-1][1]++
+		} else {
+			runs = append(runs, [2]int{n, 1})
+		}
+	}
+	return runs
+}
+
+// readQPDFValue returns the raw JSON value of a non-stream object, which
+// may be an array or a scalar as well as a dictionary
+func readQPDFValue(ctx context.Context, pdfPath, id string) (json.RawMessage, error) {
+	cmd := exec.CommandContext(ctx, "qpdf", "--json=2", "--json-key=qpdf", "--json-object="+id, pdfPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF objects: %w - %s", err, stderr.String())
+	}
+	var doc qpdfJSON
+	if err := json.Unmarshal(output, &doc); err != nil || len(doc.QPDF) != 2 {
+		return nil, fmt.Errorf("unexpected qpdf JSON output")
+	}
+	var objs map[string]struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(doc.QPDF[1], &objs); err != nil {
+		return nil, fmt.Errorf("unexpected qpdf JSON objects: %w", err)
+	}
+	return objs["obj:"+id+" 0 R"].Value, nil
+}
+
+// readPageObjects returns the object number of every page in order
+func readPageObjects(ctx context.Context, pdfPath string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "qpdf", "--json=2", "--json-key=pages", pdfPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pages: %w - %s", err, stderr.String())
+	}
+	var doc struct {
+		Pages []struct {
+			Object string `json:"object"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal(output, &doc); err != nil {
+		return nil, fmt.Errorf("unexpected qpdf JSON output: %w", err)
+	}
+	ids := make([]string, len(doc.Pages))
+	for i, p := range doc.Pages {
+		ids[i] = strings.Fields(p.Object)[0]
+	}
+	return ids, nil
+}
+
+// pdfValue serializes a qpdf JSON v2 value back to PDF syntax
+func pdfValue(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "null"
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		json.Unmarshal(raw, &s)
+		switch {
+		case strings.HasPrefix(s, "u:"):
+			return pdfTextLiteral(s[2:])
+		case strings.HasPrefix(s, "b:"):
+			return "<" + s[2:] + ">"
+		case strings.HasPrefix(s, "n:"):
+			return s[2:]
+		case strings.HasPrefix(s, "/"):
+			return pdfName(s[1:])
+		case strings.HasSuffix(s, " R"):
+			return s
+		}
+		return pdfTextLiteral(s)
+	case '[':
+		var items []json.RawMessage
+		json.Unmarshal(raw, &items)
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = pdfValue(item)
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	case '{':
+		var dict map[string]json.RawMessage
+		json.Unmarshal(raw, &dict)
+		return pdfDict(dict)
+	}
+	// Numbers, booleans and null read the same in both syntaxes
+	return string(raw)
+}
+
+// pdfDict serializes a qpdf JSON dictionary with its keys sorted
+func pdfDict(dict map[string]json.RawMessage) string {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("<<")
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s %s", pdfName(strings.TrimPrefix(k, "/")), pdfValue(dict[k]))
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
+
+// pdfName writes a name, #-escaping delimiters and bytes outside 33-126
+func pdfName(name string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 33 || c > 126 || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// pdfTextLiteral writes a text string: a literal for ASCII, else UTF-16BE hex
+func pdfTextLiteral(s string) string {
+	for _, r := range s {
+		if r > 126 || (r < 32 && r != '\n' && r != '\r' && r != '\t') {
+			var u bytes.Buffer
+			u.Write([]byte{0xFE, 0xFF})
+			for _, c := range utf16.Encode([]rune(s)) {
+				u.Write([]byte{byte(c >> 8), byte(c)})
+			}
+			return "<" + strings.ToUpper(hex.EncodeToString(u.Bytes())) + ">"
+		}
+	}
+	return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`).Replace(s) + ")"
+}
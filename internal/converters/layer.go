@@ -0,0 +1,85 @@
+package converters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pdf-forge/internal/models"
+)
+
+// Source page mappings for overlay and underlay
+const (
+	LayerSourceFirst = "first" // First page on every target page
+	LayerSourceEach  = "each"  // Page n on the n-th target page
+)
+
+// Overlay draws pages of layer over the target pages of pdf (all when
+// pageRange is empty)
+func (m *PDFManipulator) Overlay(ctx context.Context, pdf, layer []byte, pageRange string, opts *models.LayerOptions) ([]byte, error) {
+	return m.applyLayer(ctx, pdf, layer, pageRange, opts, false)
+}
+
+// Underlay draws pages of layer beneath the target pages of pdf, e.g.
+// letterhead behind generated content
+func (m *PDFManipulator) Underlay(ctx context.Context, pdf, layer []byte, pageRange string, opts *models.LayerOptions) ([]byte, error) {
+	return m.applyLayer(ctx, pdf, layer, pageRange, opts, true)
+}
+
+func (m *PDFManipulator) applyLayer(ctx context.Context, pdf, layer []byte, pageRange string, opts *models.LayerOptions, underlay bool) ([]byte, error) {
+	if opts == nil {
+		opts = &models.LayerOptions{}
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "layer-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	layerPath := filepath.Join(workDir, "layer.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+	if err := os.WriteFile(layerPath, layer, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write layer: %w", err)
+	}
+
+	count, err := countPages(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := selectPages(pageRange, count)
+	if err != nil {
+		return nil, err
+	}
+
+	// qpdf maps --from pages to the --to pages in order, then cycles
+	// through --repeat pages for the rest
+	mode := "--overlay"
+	if underlay {
+		mode = "--underlay"
+	}
+	args := []string{inputPath, mode, layerPath, "--to=" + joinPages(targets)}
+	switch opts.Source {
+	case "", LayerSourceFirst:
+		args = append(args, "--from=", "--repeat=1")
+	case LayerSourceEach:
+		args = append(args, "--from=1-z")
+	default:
+		args = append(args, "--from="+opts.Source)
+		if opts.Repeat != "" {
+			args = append(args, "--repeat="+opts.Repeat)
+		}
+	}
+	args = append(args, "--", outputPath)
+
+	if err := m.runQPDF(args...); err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", mode[2:], err)
+	}
+
+	return os.ReadFile(outputPath)
+}
@@ -247,6 +247,33 @@ func (m *PDFManipulator) PDFToImages(ctx context.Context, pdf []byte, format str
 	}
 	defer os.Remove(inputPath)
 
+	matches, err := renderPages(ctx, inputPath, outputPrefix, format, dpi, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect output images
+	var images [][]byte
+	for _, match := range matches {
+		imgData, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		images = append(images, imgData)
+		os.Remove(match)
+	}
+
+	return images, nil
+}
+
+// maxRenderDPI caps the resolution callers may render pages at: a Letter
+// page at 600 dpi is already about 34 megapixels
+const maxRenderDPI = 600
+
+// renderPages renders pages first to last (0 for the document's ends) with
+// pdftoppm, passing any extra arguments, and returns the image paths in
+// page order
+func renderPages(ctx context.Context, inputPath, outputPrefix, format string, dpi, first, last int, extra ...string) ([]string, error) {
 	if format == "" {
 		format = "jpeg"
 	}
@@ -258,6 +285,12 @@ func (m *PDFManipulator) PDFToImages(ctx context.Context, pdf []byte, format str
 	args := []string{
 		fmt.Sprintf("-r"), fmt.Sprintf("%d", dpi),
 	}
+	if first > 0 {
+		args = append(args, "-f", strconv.Itoa(first))
+	}
+	if last > 0 {
+		args = append(args, "-l", strconv.Itoa(last))
+	}
 
 	switch format {
 	case "png":
@@ -268,6 +301,7 @@ func (m *PDFManipulator) PDFToImages(ctx context.Context, pdf []byte, format str
 		args = append(args, "-jpeg")
 	}
 
+	args = append(args, extra...)
 	args = append(args, inputPath, outputPrefix)
 
 	cmd := exec.CommandContext(ctx, "pdftoppm", args...)
@@ -275,21 +309,10 @@ func (m *PDFManipulator) PDFToImages(ctx context.Context, pdf []byte, format str
 		return nil, fmt.Errorf("PDF to image conversion failed: %w", err)
 	}
 
-	// Collect output images
-	var images [][]byte
+	// pdftoppm zero-pads page numbers, so names sort in page order
 	pattern := outputPrefix + "*"
 	matches, _ := filepath.Glob(pattern)
-
-	for _, match := range matches {
-		imgData, err := os.ReadFile(match)
-		if err != nil {
-			continue
-		}
-		images = append(images, imgData)
-		os.Remove(match)
-	}
-
-	return images, nil
+	return matches, nil
 }
 
 // GetInfo returns PDF metadata and info
@@ -349,12 +372,35 @@ func (m *PDFManipulator) GetInfo(ctx context.Context, pdf []byte) (*models.PDFIn
 	return info, nil
 }
 
-// AddPageNumbers adds page numbers to a PDF
-func (m *PDFManipulator) AddPageNumbers(ctx context.Context, pdf []byte, position string, format string) ([]byte, error) {
-	// This is a complex operation that typically requires a library like pdfcpu
-	// For now, return the original PDF
-	// In production, integrate pdfcpu or use a different approach
-	return pdf, nil
+// SetMetadata replaces document metadata (Info dictionary, XMP, language)
+func (m *PDFManipulator) SetMetadata(ctx context.Context, pdf []byte, metadata *models.PDFMetadata) ([]byte, error) {
+	workDir, err := os.MkdirTemp(m.tempDir, "metadata-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	if err := writeMetadata(ctx, workDir, inputPath, outputPath, metadata); err != nil {
+		return nil, fmt.Errorf("failed to set metadata: %w", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// AddPageNumbers stamps page numbers on a single PDF
+func (m *PDFManipulator) AddPageNumbers(ctx context.Context, pdf []byte, numbering *models.PageNumbering) ([]byte, error) {
+	results, _, err := m.NumberPages(ctx, [][]byte{pdf}, numbering)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
 }
 
 // RemovePages removes specific pages from a PDF
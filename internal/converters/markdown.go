@@ -0,0 +1,296 @@
+package converters
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"pdf-forge/internal/models"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// MarkdownTheme names a built-in stylesheet
+type MarkdownTheme string
+
+const (
+	ThemeGitHub   MarkdownTheme = "github"
+	ThemeAcademic MarkdownTheme = "academic"
+	ThemeMinimal  MarkdownTheme = "minimal"
+)
+
+const defaultHighlightStyle = "github"
+
+// RenderedMarkdown is a standalone HTML document plus front matter metadata
+type RenderedMarkdown struct {
+	HTML     string
+	Metadata *models.PDFMetadata
+}
+
+// RenderMarkdown converts CommonMark/GFM to a styled HTML document.
+// YAML front matter (title, author, subject, keywords, creator, lang) is mapped
+// to PDF metadata.
+func RenderMarkdown(markdown string, opts *models.MarkdownOptions) (*RenderedMarkdown, error) {
+	if opts == nil {
+		opts = &models.MarkdownOptions{}
+	}
+
+	highlightStyle := opts.HighlightStyle
+	if highlightStyle == "" {
+		highlightStyle = defaultHighlightStyle
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM, // tables, task lists, strikethrough, autolinks
+			extension.Footnote,
+			extension.DefinitionList,
+			meta.Meta,
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(
+			gmhtml.WithUnsafe(), // Allow inline HTML, as Chrome renders it anyway
+			renderer.WithNodeRenderers(
+				util.Prioritized(&codeBlockRenderer{style: highlightStyle}, 200),
+			),
+		),
+	)
+
+	var body bytes.Buffer
+	pctx := parser.NewContext()
+	if err := md.Convert([]byte(markdown), &body, parser.WithContext(pctx)); err != nil {
+		return nil, fmt.Errorf("markdown rendering failed: %w", err)
+	}
+
+	frontMatter, err := meta.TryGet(pctx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+	metadata := frontMatterMetadata(frontMatter)
+
+	highlightCSS, err := chromaCSS(highlightStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	title := ""
+	if metadata != nil {
+		title = metadata.Title
+	}
+
+	var doc strings.Builder
+	doc.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"UTF-8\">\n")
+	if title != "" {
+		// Chrome uses <title> for the PDF Title and the {title} placeholder
+		fmt.Fprintf(&doc, "<title>%s</title>\n", html.EscapeString(title))
+	}
+	doc.WriteString("<style>\n")
+	doc.WriteString(markdownThemeCSS(MarkdownTheme(opts.Theme)))
+	doc.WriteString(markdownCommonCSS)
+	doc.WriteString(highlightCSS)
+	doc.WriteString(opts.CustomCSS)
+	doc.WriteString("\n</style>\n</head>\n<body class=\"markdown-body\">\n")
+	doc.Write(body.Bytes())
+	doc.WriteString("</body>\n</html>")
+
+	return &RenderedMarkdown{HTML: doc.String(), Metadata: metadata}, nil
+}
+
+// frontMatterMetadata maps well-known front matter keys to PDF metadata
+func frontMatterMetadata(fm map[string]interface{}) *models.PDFMetadata {
+	if len(fm) == 0 {
+		return nil
+	}
+
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			switch v := fm[k].(type) {
+			case string:
+				return v
+			case []interface{}:
+				parts := make([]string, 0, len(v))
+				for _, item := range v {
+					parts = append(parts, fmt.Sprint(item))
+				}
+				return strings.Join(parts, ", ")
+			case nil:
+				continue
+			default:
+				return fmt.Sprint(v)
+			}
+		}
+		return ""
+	}
+
+	m := &models.PDFMetadata{
+		Title:    str("title"),
+		Author:   str("author", "authors"),
+		Subject:  str("subject", "description"),
+		Keywords: str("keywords", "tags"),
+		Creator:  str("creator"),
+		Language: str("lang", "language"),
+	}
+	if m.Title == "" && m.Author == "" && m.Subject == "" && m.Keywords == "" && m.Creator == "" && m.Language == "" {
+		return nil
+	}
+	return m
+}
+
+// MergeMetadata fills empty fields of dst from src and returns the result
+func MergeMetadata(dst, src *models.PDFMetadata) *models.PDFMetadata {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		copied := *src
+		return &copied
+	}
+	merged := *dst
+	if merged.Title == "" {
+		merged.Title = src.Title
+	}
+	if merged.Author == "" {
+		merged.Author = src.Author
+	}
+	if merged.Subject == "" {
+		merged.Subject = src.Subject
+	}
+	if merged.Keywords == "" {
+		merged.Keywords = src.Keywords
+	}
+	if merged.Creator == "" {
+		merged.Creator = src.Creator
+	}
+	if merged.Language == "" {
+		merged.Language = src.Language
+	}
+	return &merged
+}
+
+// codeBlockRenderer renders fenced code blocks with chroma highlighting
+type codeBlockRenderer struct {
+	style string
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	language := string(n.Language(source))
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		// Unknown or missing language: plain escaped block
+		fmt.Fprintf(w, "<pre class=\"chroma\"><code>%s</code></pre>\n", html.EscapeString(code.String()))
+		return ast.WalkSkipChildren, nil
+	}
+
+	iterator, err := lexer.Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.Format(w, styles.Get(r.style), iterator); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// chromaCSS returns the stylesheet for a chroma highlight style
+func chromaCSS(styleName string) (string, error) {
+	var buf bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&buf, styles.Get(styleName)); err != nil {
+		return "", fmt.Errorf("failed to build highlight CSS: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func markdownThemeCSS(theme MarkdownTheme) string {
+	switch theme {
+	case ThemeAcademic:
+		return markdownAcademicCSS
+	case ThemeMinimal:
+		return markdownMinimalCSS
+	default:
+		return markdownGitHubCSS
+	}
+}
+
+// markdownCommonCSS covers elements every theme needs for print
+const markdownCommonCSS = `
+.markdown-body img { max-width: 100%; }
+.markdown-body pre { overflow-x: auto; white-space: pre-wrap; word-wrap: break-word; page-break-inside: avoid; }
+.markdown-body table { border-collapse: collapse; page-break-inside: auto; }
+.markdown-body tr { page-break-inside: avoid; }
+.markdown-body h1, .markdown-body h2, .markdown-body h3 { page-break-after: avoid; }
+.markdown-body li:has(> input[type=checkbox]) { list-style: none; }
+.markdown-body input[type=checkbox] { margin: 0 0.4em 0 -1.4em; }
+.markdown-body .footnotes { font-size: 0.85em; border-top: 1px solid #ddd; margin-top: 2em; }
+`
+
+const markdownGitHubCSS = `
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 14px; line-height: 1.6; color: #24292f; padding: 20px; }
+h1, h2 { border-bottom: 1px solid #d0d7de; padding-bottom: 0.3em; }
+h1, h2, h3, h4, h5, h6 { margin-top: 24px; margin-bottom: 16px; font-weight: 600; line-height: 1.25; }
+a { color: #0969da; text-decoration: none; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 85%; background: rgba(175,184,193,0.2); padding: 0.2em 0.4em; border-radius: 6px; }
+pre { background: #f6f8fa; padding: 16px; border-radius: 6px; font-size: 85%; line-height: 1.45; }
+pre code { background: transparent; padding: 0; font-size: 100%; }
+blockquote { margin: 0; padding: 0 1em; color: #57606a; border-left: 0.25em solid #d0d7de; }
+table { width: auto; margin-bottom: 16px; }
+th, td { padding: 6px 13px; border: 1px solid #d0d7de; }
+th { font-weight: 600; background: #f6f8fa; }
+tr:nth-child(2n) { background: #f6f8fa; }
+hr { height: 0.25em; background: #d0d7de; border: 0; }
+`
+
+const markdownAcademicCSS = `
+body { font-family: "Times New Roman", Georgia, serif; font-size: 12pt; line-height: 1.5; color: #000; padding: 0 10px; text-align: justify; hyphens: auto; }
+h1 { font-size: 20pt; text-align: center; margin: 0 0 24pt; }
+h2 { font-size: 15pt; margin: 18pt 0 8pt; }
+h3 { font-size: 13pt; font-style: italic; margin: 14pt 0 6pt; }
+p { margin: 0 0 8pt; text-indent: 1.5em; }
+a { color: #000; }
+code, pre { font-family: "Courier New", monospace; font-size: 10pt; }
+pre { border-left: 2px solid #999; padding: 6pt 10pt; text-align: left; }
+blockquote { margin: 10pt 2em; font-size: 11pt; }
+table { margin: 12pt auto; border-top: 2px solid #000; border-bottom: 2px solid #000; }
+th { border-bottom: 1px solid #000; }
+th, td { padding: 4pt 10pt; }
+.footnotes { font-size: 10pt; }
+`
+
+const markdownMinimalCSS = `
+body { font-family: Helvetica, Arial, sans-serif; font-size: 11pt; line-height: 1.5; color: #222; padding: 0; }
+h1, h2, h3, h4, h5, h6 { font-weight: 600; margin: 1.2em 0 0.5em; }
+a { color: #222; }
+code, pre { font-family: Menlo, Consolas, monospace; font-size: 9.5pt; }
+pre { padding: 8px 0; }
+blockquote { margin: 0; padding-left: 1em; border-left: 2px solid #ccc; color: #555; }
+th, td { padding: 4px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+`
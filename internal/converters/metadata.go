@@ -0,0 +1,427 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"pdf-forge/internal/models"
+)
+
+// qpdfJSON is the part of qpdf's JSON v2 output used for object updates
+type qpdfJSON struct {
+	QPDF []json.RawMessage `json:"qpdf"`
+}
+
+// qpdfObject is one entry of the qpdf object map
+type qpdfObject struct {
+	Value  map[string]json.RawMessage `json:"value,omitempty"`
+	Stream *qpdfStream                `json:"stream,omitempty"`
+}
+
+type qpdfStream struct {
+	Dict map[string]json.RawMessage `json:"dict"`
+	Data string                     `json:"data,omitempty"` // Base64, decoded
+}
+
+// UnmarshalJSON keeps dictionaries and streams. Arrays and numbers stored
+// as objects of their own (e.g. indirect stream lengths) leave Value nil
+// instead of failing the whole object map; readQPDFValue reads those.
+func (o *qpdfObject) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Value  json.RawMessage `json:"value"`
+		Stream *qpdfStream     `json:"stream"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	o.Value, o.Stream = nil, raw.Stream
+	if v := bytes.TrimSpace(raw.Value); len(v) > 0 && v[0] == '{' {
+		return json.Unmarshal(v, &o.Value)
+	}
+	return nil
+}
+
+// readQPDFObjects returns the qpdf JSON header and the requested objects
+// ("trailer" or an object number)
+func readQPDFObjects(ctx context.Context, pdfPath string, objects ...string) (json.RawMessage, map[string]qpdfObject, error) {
+	args := []string{"--json=2", "--json-key=qpdf", "--json-stream-data=inline", "--decode-level=generalized"}
+	for _, obj := range objects {
+		args = append(args, "--json-object="+obj)
+	}
+	args = append(args, pdfPath)
+
+	cmd := exec.CommandContext(ctx, "qpdf", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read PDF objects: %w - %s", err, stderr.String())
+	}
+
+	var doc qpdfJSON
+	if err := json.Unmarshal(output, &doc); err != nil || len(doc.QPDF) != 2 {
+		return nil, nil, fmt.Errorf("unexpected qpdf JSON output")
+	}
+	var objs map[string]qpdfObject
+	if err := json.Unmarshal(doc.QPDF[1], &objs); err != nil {
+		return nil, nil, fmt.Errorf("unexpected qpdf JSON objects: %w", err)
+	}
+	return doc.QPDF[0], objs, nil
+}
+
+// maxObjectID reads maxobjectid from a qpdf JSON header
+func maxObjectID(header json.RawMessage) int {
+	var h struct {
+		MaxObjectID int `json:"maxobjectid"`
+	}
+	json.Unmarshal(header, &h)
+	return h.MaxObjectID
+}
+
+// qpdfRef parses an indirect reference such as "12 0 R" into its object number
+func qpdfRef(raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.HasSuffix(s, " R") {
+		return "", false
+	}
+	return strings.Fields(s)[0], true
+}
+
+// qpdfText decodes a qpdf JSON string value ("u:..." text strings)
+func qpdfText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.HasPrefix(s, "u:") {
+		return ""
+	}
+	return s[2:]
+}
+
+func qpdfTextValue(s string) json.RawMessage {
+	b, _ := json.Marshal("u:" + s)
+	return b
+}
+
+func qpdfNameValue(s string) json.RawMessage {
+	b, _ := json.Marshal("/" + s)
+	return b
+}
+
+func qpdfRefValue(id string) json.RawMessage {
+	b, _ := json.Marshal(id + " 0 R")
+	return b
+}
+
+// pdfDate formats a time as a PDF date string, D:YYYYMMDDHHmmSS+HH'mm'
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return t.Format("D:20060102150405Z")
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%s%c%02d'%02d'", t.Format("D:20060102150405"), sign, offset/3600, offset%3600/60)
+}
+
+// parsePDFDate reads the date part of a PDF date string (best effort)
+func parsePDFDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(s, "D:")
+	if len(s) < 14 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102150405", s[:14])
+	if err != nil {
+		return time.Time{}, false
+	}
+	rest := strings.ReplaceAll(s[14:], "'", "")
+	if len(rest) >= 5 && (rest[0] == '+' || rest[0] == '-') {
+		if zt, err := time.Parse("20060102150405-0700", s[:14]+rest[:5]); err == nil {
+			return zt, true
+		}
+	}
+	return t, true
+}
+
+// writeMetadata sets the Info dictionary, the XMP packet and the catalog
+// /Lang of a PDF. Existing Info entries that aren't overridden are kept.
+func writeMetadata(ctx context.Context, workDir, inputPath, outputPath string, md *models.PDFMetadata) error {
+	header, objs, err := readQPDFObjects(ctx, inputPath, "trailer")
+	if err != nil {
+		return err
+	}
+	trailer := objs["trailer"].Value
+	rootID, ok := qpdfRef(trailer["/Root"])
+	if !ok {
+		return fmt.Errorf("PDF has no document catalog")
+	}
+
+	wanted := []string{rootID}
+	infoID, hasInfo := qpdfRef(trailer["/Info"])
+	if hasInfo {
+		wanted = append(wanted, infoID)
+	}
+	_, objs, err = readQPDFObjects(ctx, inputPath, wanted...)
+	if err != nil {
+		return err
+	}
+	catalog := objs["obj:"+rootID+" 0 R"].Value
+	if catalog == nil {
+		return fmt.Errorf("PDF has no document catalog")
+	}
+
+	info := map[string]json.RawMessage{}
+	if hasInfo {
+		if existing := objs["obj:"+infoID+" 0 R"].Value; existing != nil {
+			info = existing
+		}
+	}
+
+	// Keep PDF/A and PDF/X identification, the document identity and
+	// extension schemas (e.g. Factur-X) from an existing XMP packet
+	metadataID, hasMetadata := qpdfRef(catalog["/Metadata"])
+	_, existing, _, err := readXMP(ctx, inputPath)
+	if err != nil && !errors.Is(err, ErrNoXMP) {
+		return err
+	}
+	conformance := pdfaIdentification(existing) + pdfxIdentification(existing)
+	extensions := strings.Join(xmpExtensionPattern.FindAllString(existing, -1), "") +
+		xmpMMDescription(xmpMMIdentification(existing))
+
+	// Custom entries first so the standard fields win on conflicts.
+	// qpdf JSON takes names unescaped and encodes them on write.
+	customKeys := make([]string, 0, len(md.Custom))
+	for k := range md.Custom {
+		customKeys = append(customKeys, k)
+	}
+	sort.Strings(customKeys)
+	for _, k := range customKeys {
+		info["/"+k] = qpdfTextValue(md.Custom[k])
+	}
+
+	for key, value := range map[string]string{
+		"/Title":    md.Title,
+		"/Author":   md.Author,
+		"/Subject":  md.Subject,
+		"/Keywords": md.Keywords,
+		"/Creator":  md.Creator,
+		"/Producer": md.Producer,
+	} {
+		if value != "" {
+			info[key] = qpdfTextValue(value)
+		}
+	}
+
+	if md.CreationDate != nil {
+		info["/CreationDate"] = qpdfTextValue(pdfDate(*md.CreationDate))
+	}
+	modified := time.Now()
+	if md.ModificationDate != nil {
+		modified = *md.ModificationDate
+	}
+	info["/ModDate"] = qpdfTextValue(pdfDate(modified))
+
+	nextID := maxObjectID(header)
+	if !hasInfo {
+		nextID++
+		infoID = fmt.Sprint(nextID)
+		trailer["/Info"] = qpdfRefValue(infoID)
+	}
+	if !hasMetadata {
+		nextID++
+		metadataID = fmt.Sprint(nextID)
+		catalog["/Metadata"] = qpdfRefValue(metadataID)
+	}
+	if md.Language != "" {
+		catalog["/Lang"] = qpdfTextValue(md.Language)
+	}
+
+	packet := buildXMP(info, customKeys, md, catalog, conformance, extensions)
+	update := map[string]interface{}{
+		"trailer":                    qpdfObject{Value: trailer},
+		"obj:" + rootID + " 0 R":     qpdfObject{Value: catalog},
+		"obj:" + infoID + " 0 R":     qpdfObject{Value: info},
+		"obj:" + metadataID + " 0 R": xmpStream(packet),
+	}
+
+	return updateQPDFObjects(ctx, workDir, inputPath, outputPath, header, update)
+}
+
+// updateQPDFObjects replaces objects of a PDF with the given qpdf JSON
+// objects, creating any that don't exist yet
+func updateQPDFObjects(ctx context.Context, workDir, inputPath, outputPath string, header json.RawMessage, update map[string]interface{}) error {
+	updateJSON, err := json.Marshal(map[string]interface{}{
+		"qpdf": []interface{}{header, update},
+	})
+	if err != nil {
+		return err
+	}
+	updatePath := filepath.Join(workDir, "update.json")
+	if err := os.WriteFile(updatePath, updateJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "qpdf", inputPath, "--update-from-json="+updatePath, outputPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("qpdf object update failed: %w - %s", err, stderr.String())
+	}
+	return nil
+}
+
+var pdfaIDPattern = regexp.MustCompile(`pdfaid:(part|conformance)\s*(?:=\s*["']([^"']*)["']|>([^<]*)<)`)
+
+// pdfaIdentification extracts pdfaid:part and pdfaid:conformance from XMP
+func pdfaIdentification(xmp string) string {
+	var b strings.Builder
+	for _, m := range pdfaIDPattern.FindAllStringSubmatch(xmp, -1) {
+		value := strings.TrimSpace(m[2] + m[3])
+		if value == "" {
+			// The closing tag of the element form
+			continue
+		}
+		fmt.Fprintf(&b, "<pdfaid:%s>%s</pdfaid:%s>\n", m[1], html.EscapeString(value), m[1])
+	}
+	return b.String()
+}
+
+var pdfxIDPattern = regexp.MustCompile(`(pdfxid:GTS_PDFXVersion|pdf:Trapped)\s*(?:=\s*["']([^"']*)["']|>([^<]*)<)`)
+
+// pdfxIdentification extracts pdfxid:GTS_PDFXVersion and pdf:Trapped from XMP
+func pdfxIdentification(xmp string) string {
+	var b strings.Builder
+	for _, m := range pdfxIDPattern.FindAllStringSubmatch(xmp, -1) {
+		value := strings.TrimSpace(m[2] + m[3])
+		if value == "" {
+			// The closing tag of the element form
+			continue
+		}
+		fmt.Fprintf(&b, "<%s>%s</%s>\n", m[1], html.EscapeString(value), m[1])
+	}
+	return b.String()
+}
+
+// xmpMMPattern matches the document identity properties, also under the
+// older xapMM prefix Ghostscript may write
+var xmpMMPattern = regexp.MustCompile(`(?:xmpMM|xapMM):(DocumentID|VersionID|RenditionClass)\s*(?:=\s*["']([^"']*)["']|>([^<]*)<)`)
+
+// xmpMMIdentification extracts xmpMM:DocumentID, VersionID and
+// RenditionClass from XMP, which PDF/X requires
+func xmpMMIdentification(xmp string) string {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, m := range xmpMMPattern.FindAllStringSubmatch(xmp, -1) {
+		value := strings.TrimSpace(m[2] + m[3])
+		if value == "" || seen[m[1]] {
+			// The closing tag of the element form, or a repeat
+			continue
+		}
+		seen[m[1]] = true
+		fmt.Fprintf(&b, "<xmpMM:%s>%s</xmpMM:%s>\n", m[1], html.EscapeString(value), m[1])
+	}
+	return b.String()
+}
+
+// xmpMMDescription wraps xmpMM properties in an rdf:Description of their
+// own, kept apart from the properties buildXMP writes
+func xmpMMDescription(properties string) string {
+	if properties == "" {
+		return ""
+	}
+	return "<rdf:Description rdf:about=\"\" xmlns:xmpMM=\"http://ns.adobe.com/xap/1.0/mm/\">\n" +
+		properties + "</rdf:Description>\n"
+}
+
+// buildXMP renders an XMP packet mirroring the Info dictionary, with the
+// PDF/A or PDF/X identification properties in conformance
+func buildXMP(info map[string]json.RawMessage, customKeys []string, md *models.PDFMetadata, catalog map[string]json.RawMessage, conformance, extensions string) string {
+	esc := html.EscapeString
+	field := func(key string) string { return qpdfText(info[key]) }
+	date := func(key string) string {
+		if t, ok := parsePDFDate(field(key)); ok {
+			return t.Format(time.RFC3339)
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+ xmlns:pdfx="http://ns.adobe.com/pdfx/1.3/"
+ xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/"
+ xmlns:pdfxid="http://www.npes.org/pdfx/ns/id/">
+<dc:format>application/pdf</dc:format>
+`)
+	if v := field("/Title"); v != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(v))
+	}
+	if v := field("/Author"); v != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(v))
+	}
+	if v := field("/Subject"); v != "" {
+		fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", esc(v))
+	}
+	if v := qpdfText(catalog["/Lang"]); v != "" {
+		fmt.Fprintf(&b, "<dc:language><rdf:Bag><rdf:li>%s</rdf:li></rdf:Bag></dc:language>\n", esc(v))
+	}
+	if v := field("/Keywords"); v != "" {
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n", esc(v))
+	}
+	if v := field("/Producer"); v != "" {
+		fmt.Fprintf(&b, "<pdf:Producer>%s</pdf:Producer>\n", esc(v))
+	}
+	if v := field("/Creator"); v != "" {
+		fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", esc(v))
+	}
+	if v := date("/CreationDate"); v != "" {
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n", v)
+	}
+	if v := date("/ModDate"); v != "" {
+		fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n<xmp:MetadataDate>%s</xmp:MetadataDate>\n", v, v)
+	}
+	// PDF/A only allows schemas it declares, so custom properties of
+	// conforming files stay in the Info dictionary
+	if conformance == "" {
+		for _, k := range customKeys {
+			// pdfx property names must be valid XML names
+			if xmlName := xmpPropertyName(k); xmlName != "" {
+				fmt.Fprintf(&b, "<pdfx:%s>%s</pdfx:%s>\n", xmlName, esc(md.Custom[k]), xmlName)
+			}
+		}
+	}
+	b.WriteString(conformance)
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString(extensions)
+	b.WriteString("</rdf:RDF>\n</x:xmpmeta>\n")
+
+	// Padding lets other tools edit the packet in place
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.String()
+}
+
+var xmpNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func xmpPropertyName(key string) string {
+	if xmpNamePattern.MatchString(key) {
+		return key
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package converters
+
+import "testing"
+
+func TestPDFAIdentification(t *testing.T) {
+	tests := []struct {
+		name string
+		xmp  string
+		want string
+	}{
+		{
+			name: "element form",
+			xmp:  "<pdfaid:part>3</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>",
+			want: "<pdfaid:part>3</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n",
+		},
+		{
+			name: "double-quoted attributes",
+			xmp:  `<rdf:Description pdfaid:part="2" pdfaid:conformance="U"/>`,
+			want: "<pdfaid:part>2</pdfaid:part>\n<pdfaid:conformance>U</pdfaid:conformance>\n",
+		},
+		{
+			name: "single-quoted attributes",
+			xmp:  `<rdf:Description pdfaid:part='1' pdfaid:conformance='B'/>`,
+			want: "<pdfaid:part>1</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n",
+		},
+		{
+			name: "whitespace around values",
+			xmp:  "<pdfaid:part> 2 </pdfaid:part>",
+			want: "<pdfaid:part>2</pdfaid:part>\n",
+		},
+		{
+			name: "not PDF/A",
+			xmp:  "<dc:title>Report</dc:title>",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pdfaIdentification(tt.xmp); got != tt.want {
+				t.Errorf("pdfaIdentification() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXMPMMIdentification(t *testing.T) {
+	tests := []struct {
+		name string
+		xmp  string
+		want string
+	}{
+		{
+			name: "element form",
+			xmp:  "<xmpMM:DocumentID>uuid:1234</xmpMM:DocumentID>\n<xmpMM:VersionID>2</xmpMM:VersionID>",
+			want: "<xmpMM:DocumentID>uuid:1234</xmpMM:DocumentID>\n<xmpMM:VersionID>2</xmpMM:VersionID>\n",
+		},
+		{
+			name: "xapMM attributes",
+			xmp:  `<rdf:Description xapMM:DocumentID='uuid:1234' xapMM:RenditionClass="default"/>`,
+			want: "<xmpMM:DocumentID>uuid:1234</xmpMM:DocumentID>\n<xmpMM:RenditionClass>default</xmpMM:RenditionClass>\n",
+		},
+		{
+			name: "repeated property",
+			xmp:  "<xmpMM:DocumentID>uuid:1</xmpMM:DocumentID><xmpMM:DocumentID>uuid:2</xmpMM:DocumentID>",
+			want: "<xmpMM:DocumentID>uuid:1</xmpMM:DocumentID>\n",
+		},
+		{
+			name: "derived from another document",
+			xmp:  "<xmpMM:DerivedFrom rdf:parseType=\"Resource\"><stRef:documentID>uuid:9</stRef:documentID></xmpMM:DerivedFrom>",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := xmpMMIdentification(tt.xmp); got != tt.want {
+				t.Errorf("xmpMMIdentification() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
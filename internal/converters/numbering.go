@@ -0,0 +1,194 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// Number label positions
+const (
+	NumberTopLeft      = "top_left"
+	NumberTopCenter    = "top_center"
+	NumberTopRight     = "top_right"
+	NumberBottomLeft   = "bottom_left"
+	NumberBottomCenter = "bottom_center"
+	NumberBottomRight  = "bottom_right"
+)
+
+// numberMargin is the distance of the label from the page edge (1/3in)
+const numberMargin = 24.0
+
+// numberLabel formats the label for one page
+func numberLabel(n, total int, opts *models.PageNumbering) string {
+	if opts.Bates {
+		digits := opts.Digits
+		if digits <= 0 {
+			digits = 6
+		}
+		return fmt.Sprintf("%s%0*d%s", opts.Prefix, digits, n, opts.Suffix)
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = "{n}"
+	}
+	return strings.NewReplacer(
+		"{n}", formatNumber(n, opts.Style),
+		"{total}", formatNumber(total, opts.Style),
+	).Replace(format)
+}
+
+// formatNumber renders n as arabic or roman numerals
+func formatNumber(n int, style string) string {
+	switch style {
+	case "roman":
+		return strings.ToLower(toRoman(n))
+	case "roman_upper":
+		return toRoman(n)
+	default:
+		return strconv.Itoa(n)
+	}
+}
+
+func toRoman(n int) string {
+	if n <= 0 || n >= 4000 {
+		// No roman form; fall back to arabic
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+// buildNumberStamp renders one stamp page per box with its label
+func buildNumberStamp(boxes []pageBox, labels []string, opts *models.PageNumbering) []byte {
+	s := newStampWriter()
+	font := s.font(fontHelvetica)
+
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = 10
+	}
+	position := opts.Position
+	if position == "" {
+		position = NumberBottomCenter
+		if opts.Bates {
+			position = NumberBottomRight
+		}
+	}
+	rgb := parseColor(opts.Color, namedColors["black"])
+
+	for i, box := range boxes {
+		label := labels[i]
+		width := textWidth(fontHelvetica, label, fontSize)
+
+		x := (box.Width - width) / 2
+		switch position {
+		case NumberTopLeft, NumberBottomLeft:
+			x = numberMargin
+		case NumberTopRight, NumberBottomRight:
+			x = box.Width - numberMargin - width
+		}
+		y := numberMargin
+		if strings.HasPrefix(position, "top_") {
+			y = box.Height - numberMargin - fontSize*fontCapHeight/1000
+		}
+
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "q %s %s %s rg BT /%s %s Tf %s %s Td %s Tj ET Q\n",
+			pdfNumber(rgb[0]), pdfNumber(rgb[1]), pdfNumber(rgb[2]),
+			font, pdfNumber(fontSize), pdfNumber(x), pdfNumber(y), pdfTextString(label))
+		s.addPage(box, content.Bytes())
+	}
+	return s.finish()
+}
+
+// NumberPages stamps page numbers on each document. Bates numbers continue
+// from one document to the next; plain page numbers restart per document.
+// It returns the stamped documents and the number following the last label.
+func (m *PDFManipulator) NumberPages(ctx context.Context, pdfs [][]byte, opts *models.PageNumbering) ([][]byte, int, error) {
+	if opts == nil {
+		opts = &models.PageNumbering{}
+	}
+	start := opts.Start
+	if start <= 0 {
+		start = 1
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "numbering-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	results := make([][]byte, 0, len(pdfs))
+	next := start
+	for i, pdf := range pdfs {
+		inputPath := filepath.Join(workDir, fmt.Sprintf("input_%d.pdf", i))
+		stampPath := filepath.Join(workDir, fmt.Sprintf("stamp_%d.pdf", i))
+		outputPath := filepath.Join(workDir, fmt.Sprintf("output_%d.pdf", i))
+
+		if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+			return nil, 0, fmt.Errorf("failed to write input: %w", err)
+		}
+
+		boxes, err := readPageBoxes(ctx, inputPath)
+		if err != nil {
+			return nil, 0, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
+		first := next
+		if !opts.Bates {
+			first = start
+		}
+		total := first + len(boxes) - 1
+
+		var pages []int
+		var stamped []pageBox
+		var labels []string
+		for p := range boxes {
+			if p == 0 && opts.SkipFirst {
+				continue
+			}
+			pages = append(pages, p+1)
+			stamped = append(stamped, boxes[p])
+			labels = append(labels, numberLabel(first+p, total, opts))
+		}
+		next = total + 1
+
+		if len(pages) == 0 {
+			results = append(results, pdf)
+			continue
+		}
+
+		if err := os.WriteFile(stampPath, buildNumberStamp(stamped, labels, opts), 0644); err != nil {
+			return nil, 0, fmt.Errorf("failed to write stamp: %w", err)
+		}
+		if err := applyStamp(ctx, inputPath, stampPath, outputPath, pages, false); err != nil {
+			return nil, 0, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
+		out, err := os.ReadFile(outputPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, out)
+	}
+
+	return results, next, nil
+}
@@ -0,0 +1,171 @@
+package converters
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// ErrNoOCR is returned when OCR is requested but Tesseract isn't installed
+var ErrNoOCR = errors.New("OCR engine not available")
+
+// ocrLanguage matches Tesseract traineddata names such as eng or chi_sim
+var ocrLanguage = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// OCR recognizes the text of the selected pages (all when pageRange is
+// empty) and lays it over them as invisible text, leaving the page
+// content untouched
+func (m *PDFManipulator) OCR(ctx context.Context, pdf []byte, pageRange string, opts *models.OCROptions) ([]byte, []models.OCRPage, error) {
+	workDir, err := os.MkdirTemp(m.tempDir, "ocr-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	return ocrPDF(ctx, workDir, pdf, pageRange, opts)
+}
+
+// OCR adds a searchable text layer to every page of a converted PDF
+func (p *PDFProcessor) OCR(pdfData []byte, opts *models.OCROptions) ([]byte, error) {
+	workDir, err := os.MkdirTemp(p.tempDir, "ocr-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	output, _, err := ocrPDF(context.Background(), workDir, pdfData, "", opts)
+	return output, err
+}
+
+// ocrPDF renders each selected page with pdftoppm, runs Tesseract on the
+// image for a text-only PDF page and overlays those pages with qpdf
+func ocrPDF(ctx context.Context, workDir string, pdf []byte, pageRange string, opts *models.OCROptions) ([]byte, []models.OCRPage, error) {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return nil, nil, ErrNoOCR
+	}
+	if opts == nil {
+		opts = &models.OCROptions{}
+	}
+	languages := opts.Languages
+	if len(languages) == 0 {
+		languages = []string{"eng"}
+	}
+	for _, lang := range languages {
+		if !ocrLanguage.MatchString(lang) {
+			return nil, nil, fmt.Errorf("invalid OCR language %q", lang)
+		}
+	}
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 300
+	}
+	if dpi > maxRenderDPI {
+		return nil, nil, fmt.Errorf("%w: dpi must be at most %d", ErrInvalidOptions, maxRenderDPI)
+	}
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	textPath := filepath.Join(workDir, "text.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	count, err := countPages(ctx, inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pages, err := selectPages(pageRange, count)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]models.OCRPage, 0, len(pages))
+	textPages := make([]string, 0, len(pages))
+	for _, page := range pages {
+		prefix := filepath.Join(workDir, fmt.Sprintf("page%d", page))
+		images, err := renderPages(ctx, inputPath, prefix, "png", dpi, page, page)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(images) != 1 {
+			return nil, nil, fmt.Errorf("failed to render page %d", page)
+		}
+
+		// textonly_pdf drops the image, leaving a page of invisible text
+		// the size of the rendered page
+		base := prefix + "-ocr"
+		cmd := exec.CommandContext(ctx, "tesseract", images[0], base,
+			"-l", strings.Join(languages, "+"), "--dpi", strconv.Itoa(dpi),
+			"-c", "textonly_pdf=1", "pdf", "tsv")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, nil, fmt.Errorf("OCR failed on page %d: %w - %s", page, err, stderr.String())
+		}
+		os.Remove(images[0])
+
+		tsv, err := os.ReadFile(base + ".tsv")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read OCR result: %w", err)
+		}
+		result := parseOCRConfidence(tsv)
+		result.Page = page
+		results = append(results, result)
+		textPages = append(textPages, base+".pdf")
+	}
+
+	args := append([]string{"--empty", "--pages"}, textPages...)
+	args = append(args, "--", textPath)
+	cmd := exec.CommandContext(ctx, "qpdf", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("failed to collect OCR pages: %w - %s", err, stderr.String())
+	}
+
+	if err := applyStamp(ctx, inputPath, textPath, outputPath, pages, false); err != nil {
+		return nil, nil, err
+	}
+
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, results, nil
+}
+
+// parseOCRConfidence averages the word confidences of Tesseract TSV
+// output. Columns: level page_num block_num par_num line_num word_num
+// left top width height conf text; level 5 rows are words.
+func parseOCRConfidence(tsv []byte) models.OCRPage {
+	var result models.OCRPage
+	total := 0.0
+	scanner := bufio.NewScanner(bytes.NewReader(tsv))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 12 || fields[0] != "5" || strings.TrimSpace(fields[11]) == "" {
+			continue
+		}
+		conf, err := strconv.ParseFloat(fields[10], 64)
+		if err != nil || conf < 0 {
+			continue
+		}
+		total += conf
+		result.Words++
+	}
+	if result.Words > 0 {
+		result.Confidence = math.Round(total/float64(result.Words)*10) / 10
+	}
+	return result
+}
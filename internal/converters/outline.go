@@ -0,0 +1,309 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"pdf-forge/internal/models"
+
+	"github.com/chromedp/chromedp"
+)
+
+// outlineHeading is a heading collected from the rendered page
+type outlineHeading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Page  int    `json:"-"`
+}
+
+// collectHeadingsScript returns visible h1..hN headings in document order
+const collectHeadingsScript = `Array.from(document.querySelectorAll('h1,h2,h3,h4,h5,h6'))
+	.map(h => ({level: parseInt(h.tagName.substring(1), 10), text: (h.innerText || '').replace(/\s+/g, ' ').trim()}))
+	.filter(h => h.level <= %d && h.text !== '')`
+
+// wantsOutline reports whether bookmarks or a table of contents are requested
+func wantsOutline(opts *models.PDFOptions) bool {
+	return opts != nil && opts.Outline != nil && (opts.Outline.Bookmarks || opts.Outline.TOC)
+}
+
+func outlineMaxLevel(o *models.OutlineOptions) int {
+	if o.MaxLevel < 1 || o.MaxLevel > 6 {
+		return 3
+	}
+	return o.MaxLevel
+}
+
+// collectHeadings gathers headings from the loaded page when an outline is requested
+func collectHeadings(opts *models.PDFOptions, headings *[]outlineHeading) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if !wantsOutline(opts) {
+			return nil
+		}
+		script := fmt.Sprintf(collectHeadingsScript, outlineMaxLevel(opts.Outline))
+		if err := chromedp.Evaluate(script, headings).Do(ctx); err != nil {
+			return fmt.Errorf("failed to collect headings: %w", err)
+		}
+		return nil
+	})
+}
+
+// applyOutline post-processes a rendered PDF: it locates each heading's page,
+// optionally prepends a generated table of contents and writes the bookmark
+// outline with Ghostscript pdfmarks
+func (c *ChromeConverter) applyOutline(ctx context.Context, pdf []byte, headings []outlineHeading, opts *models.PDFOptions) ([]byte, error) {
+	if len(headings) == 0 {
+		return pdf, nil
+	}
+
+	workDir, err := os.MkdirTemp("", "pdfforge-outline-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	bodyPath := filepath.Join(workDir, "body.pdf")
+	if err := os.WriteFile(bodyPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if err := locateHeadings(ctx, bodyPath, headings); err != nil {
+		return nil, err
+	}
+
+	currentPath := bodyPath
+	if opts.Outline.TOC {
+		tocPages, err := c.prependTOC(ctx, workDir, bodyPath, headings, opts)
+		if err != nil {
+			return nil, fmt.Errorf("table of contents failed: %w", err)
+		}
+		for i := range headings {
+			headings[i].Page += tocPages
+		}
+		currentPath = filepath.Join(workDir, "with_toc.pdf")
+	}
+
+	if opts.Outline.Bookmarks {
+		outPath := filepath.Join(workDir, "outlined.pdf")
+		if err := writeBookmarks(ctx, workDir, currentPath, outPath, headings); err != nil {
+			return nil, fmt.Errorf("bookmarks failed: %w", err)
+		}
+		currentPath = outPath
+	}
+
+	return os.ReadFile(currentPath)
+}
+
+// locateHeadings finds the page of each heading by scanning the extracted
+// text in document order
+func locateHeadings(ctx context.Context, pdfPath string, headings []outlineHeading) error {
+	cmd := exec.CommandContext(ctx, "pdftotext", "-enc", "UTF-8", pdfPath, "-")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("text extraction failed: %w", err)
+	}
+
+	pages := strings.Split(string(output), "\f")
+	for i := range pages {
+		pages[i] = normalizeOutlineText(pages[i])
+	}
+
+	page, offset := 0, 0
+	for i := range headings {
+		needle := normalizeOutlineText(headings[i].Text)
+		found := false
+		for p := page; p < len(pages) && !found; p++ {
+			start := 0
+			if p == page {
+				start = offset
+			}
+			if idx := strings.Index(pages[p][start:], needle); idx >= 0 {
+				page, offset = p, start+idx+len(needle)
+				found = true
+			}
+		}
+		// Headings the text layer can't match stay on the previous heading's page
+		headings[i].Page = page + 1
+	}
+	return nil
+}
+
+// normalizeOutlineText drops whitespace and case so line wrapping and
+// CSS text-transform don't defeat matching
+func normalizeOutlineText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// prependTOC renders the table of contents and merges it in front of the body.
+// It renders twice: once to learn how many pages the TOC takes, then with
+// page numbers shifted by that amount.
+func (c *ChromeConverter) prependTOC(ctx context.Context, workDir, bodyPath string, headings []outlineHeading, opts *models.PDFOptions) (int, error) {
+	tocOpts := *opts
+	tocOpts.Outline = nil
+	tocOpts.WaitFor = nil
+
+	title := opts.Outline.TOCTitle
+	if title == "" {
+		title = "Table of Contents"
+	}
+
+	// Use wide placeholder numbers so the page count doesn't change on the final pass
+	draft, err := c.ConvertHTML(ctx, buildTOCHTML(title, headings, 999), &tocOpts)
+	if err != nil {
+		return 0, err
+	}
+	draftPath := filepath.Join(workDir, "toc_draft.pdf")
+	if err := os.WriteFile(draftPath, draft, 0644); err != nil {
+		return 0, err
+	}
+	tocPages, err := countPages(ctx, draftPath)
+	if err != nil {
+		return 0, err
+	}
+
+	toc, err := c.ConvertHTML(ctx, buildTOCHTML(title, headings, tocPages), &tocOpts)
+	if err != nil {
+		return 0, err
+	}
+	tocPath := filepath.Join(workDir, "toc.pdf")
+	if err := os.WriteFile(tocPath, toc, 0644); err != nil {
+		return 0, err
+	}
+
+	outPath := filepath.Join(workDir, "with_toc.pdf")
+	cmd := exec.CommandContext(ctx, "qpdf", "--empty", "--pages", tocPath, bodyPath, "--", outPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("merge failed: %w - %s", err, stderr.String())
+	}
+
+	return tocPages, nil
+}
+
+func buildTOCHTML(title string, headings []outlineHeading, pageOffset int) string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"><style>
+body { font-family: Arial, sans-serif; font-size: 12pt; color: #222; padding: 20px; }
+h1 { font-size: 20pt; margin-bottom: 24px; }
+.toc-entry { display: flex; align-items: baseline; margin: 6px 0; }
+.toc-entry .text { white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+.toc-entry .dots { flex: 1; border-bottom: 1px dotted #999; margin: 0 6px; }
+.toc-level-1 { font-weight: bold; margin-top: 12px; }
+</style></head><body>`)
+	fmt.Fprintf(&b, "<h1>%s</h1>", html.EscapeString(title))
+
+	minLevel := 6
+	for _, h := range headings {
+		if h.Level < minLevel {
+			minLevel = h.Level
+		}
+	}
+
+	for _, h := range headings {
+		depth := h.Level - minLevel
+		fmt.Fprintf(&b, `<div class="toc-entry toc-level-%d" style="padding-left:%.1fem"><span class="text">%s</span><span class="dots"></span><span class="page">%d</span></div>`,
+			depth+1, float64(depth)*1.5, html.EscapeString(h.Text), h.Page+pageOffset)
+	}
+
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+// writeBookmarks adds a nested outline using Ghostscript pdfmarks
+func writeBookmarks(ctx context.Context, workDir, inputPath, outputPath string, headings []outlineHeading) error {
+	marksPath := filepath.Join(workDir, "outline.ps")
+	if err := os.WriteFile(marksPath, []byte(buildOutlinePdfmarks(headings)), 0644); err != nil {
+		return err
+	}
+
+	args := []string{
+		"-sDEVICE=pdfwrite",
+		"-dNOPAUSE",
+		"-dQUIET",
+		"-dBATCH",
+		fmt.Sprintf("-sOutputFile=%s", outputPath),
+		inputPath,
+		marksPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "gs", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w - %s", err, stderr.String())
+	}
+	return nil
+}
+
+// buildOutlinePdfmarks emits one /OUT pdfmark per heading. /Count holds the
+// number of direct children, so the tree is built from heading levels first.
+func buildOutlinePdfmarks(headings []outlineHeading) string {
+	children := make([]int, len(headings))
+	depths := make([]int, len(headings))
+	var stack []int
+	for i, h := range headings {
+		for len(stack) > 0 && headings[stack[len(stack)-1]].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			children[stack[len(stack)-1]]++
+		}
+		depths[i] = len(stack)
+		stack = append(stack, i)
+	}
+
+	var b strings.Builder
+	for i, h := range headings {
+		b.WriteString("[")
+		if children[i] > 0 {
+			// Top-level entries start expanded, deeper ones collapsed
+			count := children[i]
+			if depths[i] > 0 {
+				count = -count
+			}
+			fmt.Fprintf(&b, "/Count %d ", count)
+		}
+		fmt.Fprintf(&b, "/Title %s /Page %d /View [/XYZ null null null] /OUT pdfmark\n", pdfmarkString(h.Text), h.Page)
+	}
+	b.WriteString("[/PageMode /UseOutlines /DOCVIEW pdfmark\n")
+	return b.String()
+}
+
+// pdfmarkString encodes text as a UTF-16BE hex string so any script survives
+func pdfmarkString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// countPages returns the page count of a PDF file
+func countPages(ctx context.Context, pdfPath string) (int, error) {
+	output, err := exec.CommandContext(ctx, "qpdf", "--show-npages", pdfPath).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get page count: %w", err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid page count: %w", err)
+	}
+	return count, nil
+}
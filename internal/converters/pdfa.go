@@ -0,0 +1,345 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"pdf-forge/internal/models"
+)
+
+// ErrPDFAConformance is returned when the output fails the PDF/A
+// identification check for the requested level
+var ErrPDFAConformance = errors.New("PDF/A identification check failed")
+
+// PDF/A levels
+const (
+	PDFA1b = "1b"
+	PDFA2b = "2b"
+	PDFA2u = "2u"
+	PDFA3b = "3b"
+)
+
+// AFRelationship values for PDF/A-3 attachments
+var attachmentRelationships = map[string]bool{
+	"Data":        true,
+	"Source":      true,
+	"Alternative": true,
+	"Supplement":  true,
+	"Unspecified": true,
+}
+
+// srgbProfiles are the places Ghostscript and colord install an sRGB profile
+var srgbProfiles = []string{
+	"/usr/share/color/icc/ghostscript/srgb.icc",
+	"/usr/share/ghostscript/*/iccprofiles/srgb.icc",
+	"/usr/share/color/icc/sRGB.icc",
+	"/usr/share/color/icc/colord/sRGB.icc",
+}
+
+// findSRGBProfile returns the first sRGB ICC profile found on the system
+func findSRGBProfile() string {
+	for _, pattern := range srgbProfiles {
+		matches, _ := filepath.Glob(pattern)
+		if len(matches) > 0 {
+			return matches[len(matches)-1]
+		}
+	}
+	return ""
+}
+
+// SetICCProfile overrides the sRGB profile embedded as the PDF/A output intent
+func (p *PDFProcessor) SetICCProfile(path string) {
+	p.iccProfile = path
+}
+
+// ConvertToPDFA converts a PDF to PDF/A-1b, 2b, 2u or 3b with Ghostscript,
+// embedding an sRGB output intent and, for PDF/A-3, any attachments. The
+// result is checked and ErrPDFAConformance returned if it doesn't conform.
+func (p *PDFProcessor) ConvertToPDFA(pdfData []byte, opts *models.PDFAOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &models.PDFAOptions{}
+	}
+	level := strings.ToLower(strings.TrimPrefix(strings.ToUpper(opts.Level), "PDF/A-"))
+	if level == "" {
+		level = PDFA2b
+	}
+	switch level {
+	case PDFA1b, PDFA2b, PDFA2u, PDFA3b:
+	default:
+		return nil, fmt.Errorf("unsupported PDF/A level %q (use 1b, 2b, 2u or 3b)", opts.Level)
+	}
+	if len(opts.Attachments) > 0 && level != PDFA3b {
+		return nil, fmt.Errorf("attachments require PDF/A level 3b")
+	}
+	if p.iccProfile == "" {
+		return nil, fmt.Errorf("no sRGB ICC profile found; set PDFA_ICC_PROFILE")
+	}
+
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp(p.tempDir, "pdfa-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	defPath := filepath.Join(workDir, "pdfa_def.ps")
+	gsPath := filepath.Join(workDir, "gs.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+
+	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	def, err := buildPDFADef(workDir, p.iccProfile, opts.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(defPath, []byte(def), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	args := []string{
+		"-dPDFA=" + level[:1],
+		// Drop features PDF/A forbids instead of giving up on PDF/A
+		"-dPDFACompatibilityPolicy=1",
+		"-sColorConversionStrategy=RGB",
+		"-sDEVICE=pdfwrite",
+		"-dNOPAUSE",
+		"-dBATCH",
+		"-dNOOUTERSAVE",
+		"--permit-file-read=" + workDir + "/",
+		"--permit-file-read=" + p.iccProfile,
+		fmt.Sprintf("-sOutputFile=%s", gsPath),
+		defPath,
+		inputPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "gs", args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("PDF/A conversion failed: %w - %s", err, strings.TrimSpace(output.String()))
+	}
+	if strings.Contains(output.String(), "reverting to normal PDF output") {
+		return nil, fmt.Errorf("%w: %s", ErrPDFAConformance, gsWarning(output.String()))
+	}
+
+	if err := checkPDFA(ctx, workDir, gsPath, outputPath, level); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// buildPDFADef renders the pdfmarks for the output intent and attachments
+func buildPDFADef(workDir, iccProfile string, attachments []models.Attachment) (string, error) {
+	var b strings.Builder
+	b.WriteString("%!\n")
+	b.WriteString("[/_objdef {icc_PDFA} /type /stream /OBJ pdfmark\n")
+	b.WriteString("[{icc_PDFA} << /N 3 >> /PUT pdfmark\n")
+	fmt.Fprintf(&b, "[{icc_PDFA} %s (r) file /PUT pdfmark\n", psString(iccProfile))
+	b.WriteString("[/_objdef {OutputIntent_PDFA} /type /dict /OBJ pdfmark\n")
+	b.WriteString("[{OutputIntent_PDFA} << /Type /OutputIntent /S /GTS_PDFA1 /DestOutputProfile {icc_PDFA}" +
+		" /OutputConditionIdentifier (sRGB) /Info (sRGB IEC61966-2.1) >> /PUT pdfmark\n")
+	b.WriteString("[{Catalog} << /OutputIntents [ {OutputIntent_PDFA} ] >> /PUT pdfmark\n")
+
+	if len(attachments) == 0 {
+		return b.String(), nil
+	}
+
+	modified := pdfDate(time.Now())
+	var specs []string
+	for i, att := range attachments {
+		if att.Name == "" {
+			return "", fmt.Errorf("attachment %d has no name", i+1)
+		}
+		data, err := base64.StdEncoding.DecodeString(att.Data)
+		if err != nil {
+			return "", fmt.Errorf("attachment %q: invalid base64 data: %w", att.Name, err)
+		}
+		relationship := att.Relationship
+		if relationship == "" {
+			relationship = "Unspecified"
+		}
+		if !attachmentRelationships[relationship] {
+			return "", fmt.Errorf("attachment %q: unsupported relationship %q", att.Name, att.Relationship)
+		}
+		mimeType := att.MIMEType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+
+		path := filepath.Join(workDir, fmt.Sprintf("attachment_%d", i))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write temp file: %w", err)
+		}
+
+		stream := fmt.Sprintf("{att_%d}", i)
+		spec := fmt.Sprintf("{attspec_%d}", i)
+		fmt.Fprintf(&b, "[/_objdef %s /type /stream /OBJ pdfmark\n", stream)
+		fmt.Fprintf(&b, "[%s << /Type /EmbeddedFile /Subtype %s cvn /Params << /Size %d /ModDate %s >> >> /PUT pdfmark\n",
+			stream, psString(mimeType), len(data), psString(modified))
+		fmt.Fprintf(&b, "[%s %s (r) file /PUT pdfmark\n", stream, psString(path))
+		fmt.Fprintf(&b, "[%s /CLOSE pdfmark\n", stream)
+
+		fmt.Fprintf(&b, "[/_objdef %s /type /dict /OBJ pdfmark\n", spec)
+		fmt.Fprintf(&b, "[%s << /Type /Filespec /F %s /UF %s", spec, psString(asciiFileName(att.Name)), pdfmarkString(att.Name))
+		if att.Description != "" {
+			fmt.Fprintf(&b, " /Desc %s", pdfmarkString(att.Description))
+		}
+		fmt.Fprintf(&b, " /AFRelationship /%s /EF << /F %s /UF %s >> >> /PUT pdfmark\n", relationship, stream, stream)
+		fmt.Fprintf(&b, "[/Name %s /FS %s /EMBED pdfmark\n", pdfmarkString(att.Name), spec)
+		specs = append(specs, spec)
+	}
+	fmt.Fprintf(&b, "[{Catalog} << /AF [ %s ] >> /PUT pdfmark\n", strings.Join(specs, " "))
+	return b.String(), nil
+}
+
+// psString quotes s as a PostScript literal string
+func psString(s string) string {
+	return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+}
+
+// asciiFileName replaces characters outside printable ASCII for the legacy
+// /F entry; /UF keeps the full name
+func asciiFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r > 126 {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// gsWarning picks the line explaining why Ghostscript abandoned PDF/A
+func gsWarning(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "PDF/A") || strings.Contains(line, "PDFA") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return strings.TrimSpace(output)
+}
+
+// pdfaConformancePattern matches the value of pdfaid:conformance in either
+// attribute or element form
+var pdfaConformancePattern = regexp.MustCompile(`(<pdfaid:conformance>|pdfaid:conformance\s*=\s*["'])[^"'<]*`)
+
+// checkPDFA verifies the output intent, the PDF/A part and conformance
+// level in the XMP identification and the fonts of a converted file. This
+// is an identification check, not a full validation. Level 2u additionally
+// needs a Unicode mapping for every font and is then identified as such.
+func checkPDFA(ctx context.Context, workDir, inputPath, outputPath, level string) error {
+	_, objs, err := readQPDFObjects(ctx, inputPath, "trailer")
+	if err != nil {
+		return err
+	}
+	rootID, ok := qpdfRef(objs["trailer"].Value["/Root"])
+	if !ok {
+		return fmt.Errorf("%w: no document catalog", ErrPDFAConformance)
+	}
+	header, objs, err := readQPDFObjects(ctx, inputPath, rootID)
+	if err != nil {
+		return err
+	}
+	catalog := objs["obj:"+rootID+" 0 R"].Value
+	if _, ok := catalog["/OutputIntents"]; !ok {
+		return fmt.Errorf("%w: no output intent", ErrPDFAConformance)
+	}
+	metadataID, ok := qpdfRef(catalog["/Metadata"])
+	if !ok {
+		return fmt.Errorf("%w: no XMP metadata", ErrPDFAConformance)
+	}
+	_, objs, err = readQPDFObjects(ctx, inputPath, metadataID)
+	if err != nil {
+		return err
+	}
+	stream := objs["obj:"+metadataID+" 0 R"].Stream
+	if stream == nil {
+		return fmt.Errorf("%w: no XMP metadata", ErrPDFAConformance)
+	}
+	xmp, err := base64.StdEncoding.DecodeString(stream.Data)
+	if err != nil {
+		return fmt.Errorf("%w: unreadable XMP metadata", ErrPDFAConformance)
+	}
+	// Ghostscript always writes conformance B; 2u is B plus Unicode text
+	id := pdfaIdentification(string(xmp))
+	if !strings.Contains(id, "<pdfaid:part>"+level[:1]+"</pdfaid:part>") ||
+		!strings.Contains(id, "<pdfaid:conformance>B</pdfaid:conformance>") {
+		return fmt.Errorf("%w: output is not identified as PDF/A-%sb", ErrPDFAConformance, level[:1])
+	}
+
+	fonts, err := listFonts(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	for _, f := range fonts {
+		if !f.embedded {
+			return fmt.Errorf("%w: font %s is not embedded", ErrPDFAConformance, f.name)
+		}
+		if level == PDFA2u && !f.unicode {
+			return fmt.Errorf("%w: font %s has no Unicode mapping, required by PDF/A-2u", ErrPDFAConformance, f.name)
+		}
+	}
+
+	if level != PDFA2u {
+		return os.Rename(inputPath, outputPath)
+	}
+
+	packet := pdfaConformancePattern.ReplaceAll(xmp, []byte("${1}U"))
+	update := map[string]interface{}{
+		"obj:" + metadataID + " 0 R": xmpStream(string(packet)),
+	}
+	return updateQPDFObjects(ctx, workDir, inputPath, outputPath, header, update)
+}
+
+// pdfFont is one row of pdffonts output
+type pdfFont struct {
+	name     string
+	embedded bool
+	unicode  bool
+}
+
+// listFonts reads the fonts used by a PDF with pdffonts
+func listFonts(ctx context.Context, pdfPath string) ([]pdfFont, error) {
+	cmd := exec.CommandContext(ctx, "pdffonts", pdfPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fonts: %w - %s", err, stderr.String())
+	}
+
+	var fonts []pdfFont
+	started := false
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "---") {
+			started = true
+			continue
+		}
+		fields := strings.Fields(line)
+		// name type... emb sub uni object-id generation
+		if !started || len(fields) < 6 {
+			continue
+		}
+		n := len(fields)
+		fonts = append(fonts, pdfFont{
+			name:     fields[0],
+			embedded: fields[n-5] == "yes",
+			unicode:  fields[n-3] == "yes",
+		})
+	}
+	return fonts, nil
+}
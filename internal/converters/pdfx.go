@@ -0,0 +1,387 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pdf-forge/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrPDFXConformance is returned when the output can't be made to conform to
+// the requested PDF/X standard
+var ErrPDFXConformance = errors.New("PDF/X conformance failed")
+
+// PDF/X standards
+const (
+	PDFX1a = "X-1a"
+	PDFX4  = "X-4"
+)
+
+// pdfxVersions are the GTS_PDFXVersion values identifying each standard
+var pdfxVersions = map[string]string{
+	PDFX1a: "PDF/X-1a:2003",
+	PDFX4:  "PDF/X-4",
+}
+
+// Crop marks start this far outside the bleed and run this long, in points
+const (
+	cropMarkOffset = 3
+	cropMarkLength = 18
+)
+
+// cmykProfiles are the places Ghostscript installs its default CMYK profile
+var cmykProfiles = []string{
+	"/usr/share/color/icc/ghostscript/default_cmyk.icc",
+	"/usr/share/ghostscript/*/iccprofiles/default_cmyk.icc",
+}
+
+// findCMYKProfile returns the first CMYK ICC profile found on the system
+func findCMYKProfile() string {
+	for _, pattern := range cmykProfiles {
+		matches, _ := filepath.Glob(pattern)
+		if len(matches) > 0 {
+			return matches[len(matches)-1]
+		}
+	}
+	return ""
+}
+
+// SetCMYKProfile overrides the CMYK profile used for PDF/X when a request
+// doesn't supply one
+func (p *PDFProcessor) SetCMYKProfile(path string) {
+	p.cmykProfile = path
+}
+
+// ConvertToPDFX prepares a PDF for commercial printing as PDF/X-1a or
+// PDF/X-4. Ghostscript converts all colors to CMYK for the output profile,
+// which is embedded as the output intent; X-1a also flattens transparency.
+// Each page's visible area becomes its TrimBox, with the bleed and any crop
+// marks added around it.
+func (p *PDFProcessor) ConvertToPDFX(pdfData []byte, opts *models.PrintProfile) ([]byte, error) {
+	if opts == nil {
+		opts = &models.PrintProfile{}
+	}
+	standard := PDFX4
+	switch strings.TrimPrefix(strings.ToUpper(opts.Standard), "PDF/") {
+	case "", "X-4":
+	case "X-1A":
+		standard = PDFX1a
+	default:
+		return nil, fmt.Errorf("unsupported PDF/X standard %q (use X-1a or X-4)", opts.Standard)
+	}
+	if opts.Bleed < 0 {
+		return nil, fmt.Errorf("bleed can't be negative")
+	}
+	condition := opts.OutputCondition
+	if condition == "" {
+		condition = "Custom"
+	}
+
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp(p.tempDir, "pdfx-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	defPath := filepath.Join(workDir, "pdfx_def.ps")
+	gsPath := filepath.Join(workDir, "gs.pdf")
+	bleedPath := filepath.Join(workDir, "bleed.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+
+	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	profile := p.cmykProfile
+	if opts.ICCProfile != "" {
+		data, err := base64.StdEncoding.DecodeString(opts.ICCProfile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 icc_profile: %w", err)
+		}
+		profile = filepath.Join(workDir, "output.icc")
+		if err := os.WriteFile(profile, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write temp file: %w", err)
+		}
+	}
+	if profile == "" {
+		return nil, fmt.Errorf("no CMYK ICC profile found; supply icc_profile or set PDFX_ICC_PROFILE")
+	}
+	if err := checkCMYKProfile(profile); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(defPath, []byte(buildPDFXDef(profile, condition)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	// X-1a forbids transparency, which Ghostscript flattens below PDF 1.4
+	compatibility := "1.6"
+	if standard == PDFX1a {
+		compatibility = "1.3"
+	}
+	args := []string{
+		"-dCompatibilityLevel=" + compatibility,
+		"-sColorConversionStrategy=CMYK",
+		"-sProcessColorModel=DeviceCMYK",
+		"-sOutputICCProfile=" + profile,
+		"-dEmbedAllFonts=true",
+		"-sDEVICE=pdfwrite",
+		"-dNOPAUSE",
+		"-dBATCH",
+		"-dNOOUTERSAVE",
+		"--permit-file-read=" + workDir + "/",
+		"--permit-file-read=" + profile,
+		fmt.Sprintf("-sOutputFile=%s", gsPath),
+		defPath,
+		inputPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "gs", args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("PDF/X conversion failed: %w - %s", err, strings.TrimSpace(output.String()))
+	}
+
+	if err := checkPDFX(ctx, gsPath); err != nil {
+		return nil, err
+	}
+	if err := addBleed(ctx, workDir, gsPath, bleedPath, opts.Bleed, opts.CropMarks); err != nil {
+		return nil, err
+	}
+	if err := identifyPDFX(ctx, workDir, bleedPath, outputPath, pdfxVersions[standard]); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// checkCMYKProfile makes sure a file is an ICC profile for CMYK output
+func checkCMYKProfile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read ICC profile: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 40)
+	if _, err := f.Read(header); err != nil || string(header[36:40]) != "acsp" {
+		return fmt.Errorf("output profile is not an ICC profile")
+	}
+	if string(header[16:20]) != "CMYK" {
+		return fmt.Errorf("output profile is a %s profile, PDF/X needs CMYK", strings.TrimSpace(string(header[16:20])))
+	}
+	return nil
+}
+
+// buildPDFXDef renders the pdfmarks for the output intent. Registered
+// conditions such as FOGRA39 name the ICC registry; the profile is
+// embedded either way.
+func buildPDFXDef(iccProfile, condition string) string {
+	var b strings.Builder
+	b.WriteString("%!\n")
+	b.WriteString("[/_objdef {icc_PDFX} /type /stream /OBJ pdfmark\n")
+	b.WriteString("[{icc_PDFX} << /N 4 >> /PUT pdfmark\n")
+	fmt.Fprintf(&b, "[{icc_PDFX} %s (r) file /PUT pdfmark\n", psString(iccProfile))
+	b.WriteString("[/_objdef {OutputIntent_PDFX} /type /dict /OBJ pdfmark\n")
+	fmt.Fprintf(&b, "[{OutputIntent_PDFX} << /Type /OutputIntent /S /GTS_PDFX /DestOutputProfile {icc_PDFX}"+
+		" /OutputConditionIdentifier %s /Info %s", psString(condition), psString(condition))
+	if condition != "Custom" {
+		b.WriteString(" /RegistryName (http://www.color.org)")
+	}
+	b.WriteString(" >> /PUT pdfmark\n")
+	b.WriteString("[{Catalog} << /OutputIntents [ {OutputIntent_PDFX} ] >> /PUT pdfmark\n")
+	return b.String()
+}
+
+// checkPDFX verifies the output intent and fonts of a converted file
+func checkPDFX(ctx context.Context, pdfPath string) error {
+	_, objs, err := readQPDFObjects(ctx, pdfPath, "trailer")
+	if err != nil {
+		return err
+	}
+	rootID, ok := qpdfRef(objs["trailer"].Value["/Root"])
+	if !ok {
+		return fmt.Errorf("%w: no document catalog", ErrPDFXConformance)
+	}
+	_, objs, err = readQPDFObjects(ctx, pdfPath, rootID)
+	if err != nil {
+		return err
+	}
+	if _, ok := objs["obj:"+rootID+" 0 R"].Value["/OutputIntents"]; !ok {
+		return fmt.Errorf("%w: no output intent", ErrPDFXConformance)
+	}
+
+	fonts, err := listFonts(ctx, pdfPath)
+	if err != nil {
+		return err
+	}
+	for _, f := range fonts {
+		if !f.embedded {
+			return fmt.Errorf("%w: font %s is not embedded", ErrPDFXConformance, f.name)
+		}
+	}
+	return nil
+}
+
+// addBleed makes each page's TrimBox (its visible area unless it has one)
+// the finished size and widens the page by the bleed, plus room for crop
+// marks. Content is clipped to the BleedBox so nothing runs into the marks.
+func addBleed(ctx context.Context, workDir, inputPath, outputPath string, bleed float64, cropMarks bool) error {
+	margin := bleed
+	if cropMarks {
+		margin += cropMarkOffset + cropMarkLength
+	}
+
+	return editPageTree(ctx, workDir, inputPath, outputPath, "", func(t *pageTree, key string, edit func(string) map[string]json.RawMessage) error {
+		trim, ok := qpdfRect(t.objs[key].Value["/TrimBox"])
+		if !ok {
+			if trim, _, ok = t.geometry(key); !ok {
+				return fmt.Errorf("page has no MediaBox")
+			}
+		}
+		bleedBox := outsetRect(trim, bleed)
+
+		page := edit(key)
+		page["/MediaBox"] = qpdfRectValue(outsetRect(trim, margin))
+		delete(page, "/CropBox")
+		if t.inherited(key, "/CropBox") != nil {
+			page["/CropBox"] = page["/MediaBox"]
+		}
+		page["/TrimBox"] = qpdfRectValue(trim)
+		page["/BleedBox"] = qpdfRectValue(bleedBox)
+		if !cropMarks {
+			return nil
+		}
+
+		contents, err := t.contents(ctx, page["/Contents"])
+		if err != nil {
+			return err
+		}
+		pre := t.addStream(fmt.Sprintf("q %s %s %s %s re W n\n", pdfNumber(bleedBox[0]), pdfNumber(bleedBox[1]),
+			pdfNumber(bleedBox[2]-bleedBox[0]), pdfNumber(bleedBox[3]-bleedBox[1])))
+		post := t.addStream("\nQ\n" + cropMarkContent(trim, bleed))
+		page["/Contents"] = qpdfArray(append(append([]json.RawMessage{pre}, contents...), post))
+		return nil
+	})
+}
+
+// cropMarkContent draws marks in line with the trim edges at each corner,
+// in registration color so they print on every plate
+func cropMarkContent(trim [4]float64, bleed float64) string {
+	var b strings.Builder
+	b.WriteString("q 0.25 w 1 1 1 1 K\n")
+	start, end := bleed+cropMarkOffset, bleed+cropMarkOffset+cropMarkLength
+	for _, corner := range [][4]float64{
+		{trim[0], trim[1], -1, -1}, {trim[2], trim[1], 1, -1},
+		{trim[0], trim[3], -1, 1}, {trim[2], trim[3], 1, 1},
+	} {
+		x, y, dx, dy := corner[0], corner[1], corner[2], corner[3]
+		fmt.Fprintf(&b, "%s %s m %s %s l S\n", pdfNumber(x+dx*start), pdfNumber(y), pdfNumber(x+dx*end), pdfNumber(y))
+		fmt.Fprintf(&b, "%s %s m %s %s l S\n", pdfNumber(x), pdfNumber(y+dy*start), pdfNumber(x), pdfNumber(y+dy*end))
+	}
+	b.WriteString("Q\n")
+	return b.String()
+}
+
+// outsetRect grows a rectangle by d on every side
+func outsetRect(r [4]float64, d float64) [4]float64 {
+	return [4]float64{r[0] - d, r[1] - d, r[2] + d, r[3] + d}
+}
+
+// identifyPDFX sets the PDF/X version and trapping state in the Info
+// dictionary and the XMP packet, which both standards require to agree.
+// A missing title is filled in, as PDF/X requires one.
+func identifyPDFX(ctx context.Context, workDir, inputPath, outputPath, version string) error {
+	header, objs, err := readQPDFObjects(ctx, inputPath, "trailer")
+	if err != nil {
+		return err
+	}
+	trailer := objs["trailer"].Value
+	rootID, ok := qpdfRef(trailer["/Root"])
+	if !ok {
+		return fmt.Errorf("%w: no document catalog", ErrPDFXConformance)
+	}
+
+	wanted := []string{rootID}
+	infoID, hasInfo := qpdfRef(trailer["/Info"])
+	if hasInfo {
+		wanted = append(wanted, infoID)
+	}
+	_, objs, err = readQPDFObjects(ctx, inputPath, wanted...)
+	if err != nil {
+		return err
+	}
+	catalog := objs["obj:"+rootID+" 0 R"].Value
+	info := map[string]json.RawMessage{}
+	if hasInfo && objs["obj:"+infoID+" 0 R"].Value != nil {
+		info = objs["obj:"+infoID+" 0 R"].Value
+	}
+
+	now := qpdfTextValue(pdfDate(time.Now()))
+	if qpdfText(info["/Title"]) == "" {
+		info["/Title"] = qpdfTextValue("Untitled")
+	}
+	if _, ok := info["/CreationDate"]; !ok {
+		info["/CreationDate"] = now
+	}
+	info["/ModDate"] = now
+	info["/GTS_PDFXVersion"] = qpdfTextValue(version)
+	info["/Trapped"] = qpdfNameValue("False")
+
+	nextID := maxObjectID(header)
+	if !hasInfo {
+		nextID++
+		infoID = fmt.Sprint(nextID)
+		trailer["/Info"] = qpdfRefValue(infoID)
+	}
+	metadataID, ok := qpdfRef(catalog["/Metadata"])
+	if !ok {
+		nextID++
+		metadataID = fmt.Sprint(nextID)
+		catalog["/Metadata"] = qpdfRefValue(metadataID)
+	}
+
+	// Keep the document identity and extension schemas of Ghostscript's
+	// packet. PDF/X-4 requires xmpMM:DocumentID and VersionID.
+	_, existing, _, err := readXMP(ctx, inputPath)
+	if err != nil && !errors.Is(err, ErrNoXMP) {
+		return err
+	}
+	identity := xmpMMIdentification(existing)
+	if !strings.Contains(identity, "<xmpMM:DocumentID>") {
+		identity += fmt.Sprintf("<xmpMM:DocumentID>uuid:%s</xmpMM:DocumentID>\n", uuid.New())
+	}
+	if !strings.Contains(identity, "<xmpMM:VersionID>") {
+		identity += "<xmpMM:VersionID>1</xmpMM:VersionID>\n"
+	}
+	if !strings.Contains(identity, "<xmpMM:RenditionClass>") {
+		identity += "<xmpMM:RenditionClass>default</xmpMM:RenditionClass>\n"
+	}
+	extensions := strings.Join(xmpExtensionPattern.FindAllString(existing, -1), "") + xmpMMDescription(identity)
+
+	conformance := fmt.Sprintf("<pdfxid:GTS_PDFXVersion>%s</pdfxid:GTS_PDFXVersion>\n<pdf:Trapped>False</pdf:Trapped>\n", version)
+	packet := buildXMP(info, nil, &models.PDFMetadata{}, catalog, conformance, extensions)
+	update := map[string]interface{}{
+		"trailer":                    qpdfObject{Value: trailer},
+		"obj:" + rootID + " 0 R":     qpdfObject{Value: catalog},
+		"obj:" + infoID + " 0 R":     qpdfObject{Value: info},
+		"obj:" + metadataID + " 0 R": xmpStream(packet),
+	}
+	return updateQPDFObjects(ctx, workDir, inputPath, outputPath, header, update)
+}
@@ -2,6 +2,9 @@ package converters
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,9 +14,17 @@ import (
 	"pdf-forge/internal/models"
 )
 
+// ErrInvalidOptions is returned when post-processing options conflict
+var ErrInvalidOptions = errors.New("invalid options")
+
 // PDFProcessor handles post-processing of PDFs (security, watermarks, etc.)
 type PDFProcessor struct {
-	tempDir string
+	tempDir     string
+	iccProfile  string         // sRGB output intent for PDF/A
+	cmykProfile string         // Default CMYK output intent for PDF/X
+	signer      *Signer        // Certificate for Sign, nil when not configured
+	tsaURL      string         // Default RFC 3161 timestamp authority
+	trustStore  *x509.CertPool // Roots for signature validation, nil for the system roots
 }
 
 // NewPDFProcessor creates a new processor
@@ -23,7 +34,7 @@ func NewPDFProcessor() (*PDFProcessor, error) {
 		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 
-	return &PDFProcessor{tempDir: tempDir}, nil
+	return &PDFProcessor{tempDir: tempDir, iccProfile: findSRGBProfile(), cmykProfile: findCMYKProfile()}, nil
 }
 
 // Close cleans up temporary files
@@ -51,7 +62,22 @@ func (p *PDFProcessor) ApplySecurity(pdfData []byte, security *models.PDFSecurit
 	defer os.Remove(inputPath)
 	defer os.Remove(outputPath)
 
-	// Build qpdf command for encryption
+	args := append(encryptionArgs(security), "--", inputPath, outputPath)
+
+	cmd := exec.Command("qpdf", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("qpdf encryption failed: %w - %s", err, stderr.String())
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// encryptionArgs builds the qpdf --encrypt options for a security setting.
+// 128-bit keys use AES rather than the legacy RC4 cipher.
+func encryptionArgs(security *models.PDFSecurity) []string {
 	args := []string{
 		"--encrypt",
 		security.UserPassword,
@@ -67,130 +93,105 @@ func (p *PDFProcessor) ApplySecurity(pdfData []byte, security *models.PDFSecurit
 	if keyBits == 256 {
 		args = append(args, "256")
 	} else {
-		args = append(args, "128")
+		args = append(args, "128", "--use-aes=y")
 	}
 
-	// Set permissions for 256-bit encryption
-	if keyBits == 256 {
-		// Build permissions string
-		permissions := []string{}
-		if security.AllowPrinting {
-			permissions = append(permissions, "--print=full")
-		} else {
-			permissions = append(permissions, "--print=none")
-		}
-
-		if security.AllowModifying {
-			permissions = append(permissions, "--modify=all")
-		} else {
-			permissions = append(permissions, "--modify=none")
-		}
-
-		if security.AllowCopying {
-			permissions = append(permissions, "--extract=y")
-		} else {
-			permissions = append(permissions, "--extract=n")
-		}
-
-		args = append(args, permissions...)
+	// qpdf takes the same permission values for both key lengths; the
+	// 40-bit y/n forms are rejected for 128-bit keys
+	if security.AllowPrinting {
+		args = append(args, "--print=full")
 	} else {
-		// 128-bit permissions
-		if !security.AllowPrinting {
-			args = append(args, "--print=n")
-		}
-		if !security.AllowModifying {
-			args = append(args, "--modify=n")
-		}
-		if !security.AllowCopying {
-			args = append(args, "--extract=n")
-		}
+		args = append(args, "--print=none")
 	}
 
-	args = append(args, "--", inputPath, outputPath)
-
-	cmd := exec.Command("qpdf", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	if security.AllowModifying {
+		args = append(args, "--modify=all")
+	} else {
+		args = append(args, "--modify=none")
+	}
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("qpdf encryption failed: %w - %s", err, stderr.String())
+	if security.AllowCopying {
+		args = append(args, "--extract=y")
+	} else {
+		args = append(args, "--extract=n")
 	}
 
-	return os.ReadFile(outputPath)
+	return args
 }
 
-// ApplyWatermark applies a text watermark to PDF pages
+// ApplyWatermark stamps a text or image watermark onto the selected pages,
+// over the content (foreground) or beneath it (background)
 func (p *PDFProcessor) ApplyWatermark(pdfData []byte, watermark *models.Watermark) ([]byte, error) {
-	if watermark == nil || watermark.Text == "" {
+	if watermark == nil || (watermark.Text == "" && watermark.Image == "") {
 		return pdfData, nil
 	}
 
-	// Write input PDF
-	inputPath := filepath.Join(p.tempDir, "input_wm.pdf")
-	outputPath := filepath.Join(p.tempDir, "output_wm.pdf")
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp(p.tempDir, "watermark-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	stampPath := filepath.Join(workDir, "stamp.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
 
 	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
 		return nil, fmt.Errorf("failed to write temp file: %w", err)
 	}
-	defer os.Remove(inputPath)
-	defer os.Remove(outputPath)
 
-	// Set defaults
-	fontSize := watermark.FontSize
-	if fontSize <= 0 {
-		fontSize = 48
+	boxes, err := readPageBoxes(ctx, inputPath)
+	if err != nil {
+		return nil, err
 	}
-
-	opacity := watermark.Opacity
-	if opacity <= 0 || opacity > 1 {
-		opacity = 0.3
+	pages, err := selectPages(watermark.Pages, len(boxes))
+	if err != nil {
+		return nil, err
 	}
 
-	rotation := watermark.Rotation
-	if rotation == 0 {
-		rotation = 45
+	selected := make([]pageBox, len(pages))
+	for i, page := range pages {
+		selected[i] = boxes[page-1]
 	}
-
-	color := watermark.Color
-	if color == "" {
-		color = "gray"
+	stamp, err := buildWatermarkStamp(watermark, selected)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(stampPath, stamp, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
 	}
 
-	// Build watermark specification for qpdf
-	// Note: qpdf doesn't directly support watermarks, so we use an alternative approach
-	// For production, consider using pdfcpu or a dedicated watermarking library
+	underlay := watermark.Layer == WatermarkBackground
+	if err := applyStamp(ctx, inputPath, stampPath, outputPath, pages, underlay); err != nil {
+		return nil, err
+	}
 
-	// Fallback: copy original for now
-	// In production, integrate pdfcpu or pdftk for watermarking
-	return pdfData, nil
+	return os.ReadFile(outputPath)
 }
 
-// SetMetadata sets PDF metadata
+// SetMetadata writes metadata to the Info dictionary and XMP packet
 func (p *PDFProcessor) SetMetadata(pdfData []byte, metadata *models.PDFMetadata) ([]byte, error) {
 	if metadata == nil {
 		return pdfData, nil
 	}
 
-	// Write input PDF
-	inputPath := filepath.Join(p.tempDir, "input_meta.pdf")
-	outputPath := filepath.Join(p.tempDir, "output_meta.pdf")
+	workDir, err := os.MkdirTemp(p.tempDir, "metadata-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
 
 	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
 		return nil, fmt.Errorf("failed to write temp file: %w", err)
 	}
-	defer os.Remove(inputPath)
-	defer os.Remove(outputPath)
-
-	// Use exiftool or qpdf for metadata
-	// qpdf can preserve but not set metadata directly
-	// For production, use pdfcpu API or pikepdf
 
-	args := []string{"--linearize", inputPath, outputPath}
-	cmd := exec.Command("qpdf", args...)
-
-	if err := cmd.Run(); err != nil {
-		// If qpdf fails, return original
-		return pdfData, nil
+	if err := writeMetadata(context.Background(), workDir, inputPath, outputPath, metadata); err != nil {
+		return nil, err
 	}
 
 	return os.ReadFile(outputPath)
@@ -289,9 +290,30 @@ func (p *PDFProcessor) Process(pdfData []byte, opts *models.PDFOptions) ([]byte,
 		return pdfData, nil
 	}
 
+	if opts.PDFA != nil && opts.Security != nil && (opts.Security.UserPassword != "" || opts.Security.OwnerPassword != "") {
+		return nil, fmt.Errorf("%w: PDF/A documents cannot be encrypted", ErrInvalidOptions)
+	}
+	if opts.PrintProfile != nil && opts.Security != nil && (opts.Security.UserPassword != "" || opts.Security.OwnerPassword != "") {
+		return nil, fmt.Errorf("%w: PDF/X documents cannot be encrypted", ErrInvalidOptions)
+	}
+	if opts.PDFA != nil && opts.PrintProfile != nil {
+		return nil, fmt.Errorf("%w: PDF/A and PDF/X output cannot be combined", ErrInvalidOptions)
+	}
+	if opts.Sign != nil && opts.Security != nil && (opts.Security.UserPassword != "" || opts.Security.OwnerPassword != "") {
+		return nil, fmt.Errorf("%w: signed documents cannot be encrypted", ErrInvalidOptions)
+	}
+
 	var err error
 
-	// Apply watermark first
+	// Recognize text before anything is drawn over the page images
+	if opts.OCR != nil {
+		pdfData, err = p.OCR(pdfData, opts.OCR)
+		if err != nil {
+			return nil, fmt.Errorf("OCR failed: %w", err)
+		}
+	}
+
+	// Apply watermark next
 	if opts.Watermark != nil {
 		pdfData, err = p.ApplyWatermark(pdfData, opts.Watermark)
 		if err != nil {
@@ -299,6 +321,23 @@ func (p *PDFProcessor) Process(pdfData []byte, opts *models.PDFOptions) ([]byte,
 		}
 	}
 
+	// Convert to PDF/A before metadata so the XMP packet keeps the
+	// PDF/A identification
+	if opts.PDFA != nil {
+		pdfData, err = p.ConvertToPDFA(pdfData, opts.PDFA)
+		if err != nil {
+			return nil, fmt.Errorf("PDF/A failed: %w", err)
+		}
+	}
+
+	// Likewise PDF/X, whose identification is also kept in the XMP packet
+	if opts.PrintProfile != nil {
+		pdfData, err = p.ConvertToPDFX(pdfData, opts.PrintProfile)
+		if err != nil {
+			return nil, fmt.Errorf("PDF/X failed: %w", err)
+		}
+	}
+
 	// Apply metadata
 	if opts.Metadata != nil {
 		pdfData, err = p.SetMetadata(pdfData, opts.Metadata)
@@ -315,38 +354,15 @@ func (p *PDFProcessor) Process(pdfData []byte, opts *models.PDFOptions) ([]byte,
 		}
 	}
 
-	return pdfData, nil
-}
-
-// ConvertToPDFA converts PDF to PDF/A format for archival
-func (p *PDFProcessor) ConvertToPDFA(pdfData []byte) ([]byte, error) {
-	inputPath := filepath.Join(p.tempDir, "input_pdfa.pdf")
-	outputPath := filepath.Join(p.tempDir, "output_pdfa.pdf")
-
-	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write temp file: %w", err)
-	}
-	defer os.Remove(inputPath)
-	defer os.Remove(outputPath)
-
-	// Use Ghostscript for PDF/A conversion
-	args := []string{
-		"-dPDFA=2",
-		"-dBATCH",
-		"-dNOPAUSE",
-		"-dNOOUTERSAVE",
-		"-sDEVICE=pdfwrite",
-		"-sColorConversionStrategy=UseDeviceIndependentColor",
-		fmt.Sprintf("-sOutputFile=%s", outputPath),
-		inputPath,
-	}
-
-	cmd := exec.Command("gs", args...)
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("PDF/A conversion failed: %w", err)
+	// Sign the final bytes; any later change would break the signature
+	if opts.Sign != nil {
+		pdfData, err = p.Sign(pdfData, opts.Sign)
+		if err != nil {
+			return nil, fmt.Errorf("signing failed: %w", err)
+		}
 	}
 
-	return os.ReadFile(outputPath)
+	return pdfData, nil
 }
 
 // StreamingCopy copies PDF data efficiently
@@ -0,0 +1,71 @@
+package converters
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"pdf-forge/internal/models"
+)
+
+func TestProcessRejectsConflictingOptions(t *testing.T) {
+	encrypted := &models.PDFSecurity{UserPassword: "secret"}
+	tests := []struct {
+		name string
+		opts *models.PDFOptions
+	}{
+		{"PDF/A with encryption", &models.PDFOptions{PDFA: &models.PDFAOptions{}, Security: encrypted}},
+		{"PDF/X with encryption", &models.PDFOptions{PrintProfile: &models.PrintProfile{}, Security: encrypted}},
+		{"PDF/A with PDF/X", &models.PDFOptions{PDFA: &models.PDFAOptions{}, PrintProfile: &models.PrintProfile{}}},
+		{"signing with encryption", &models.PDFOptions{Sign: &models.SignatureOptions{}, Security: encrypted}},
+	}
+	p := &PDFProcessor{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.Process([]byte("%PDF-1.4"), tt.opts); !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("Process() error = %v, want ErrInvalidOptions", err)
+			}
+		})
+	}
+}
+
+func TestEncryptionArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		security models.PDFSecurity
+		want     []string
+	}{
+		{
+			name:     "256-bit default, nothing allowed",
+			security: models.PDFSecurity{UserPassword: "u", OwnerPassword: "o"},
+			want:     []string{"--encrypt", "u", "o", "256", "--print=none", "--modify=none", "--extract=n"},
+		},
+		{
+			name:     "256-bit, everything allowed",
+			security: models.PDFSecurity{UserPassword: "u", OwnerPassword: "o", EncryptionBits: 256, AllowPrinting: true, AllowModifying: true, AllowCopying: true},
+			want:     []string{"--encrypt", "u", "o", "256", "--print=full", "--modify=all", "--extract=y"},
+		},
+		{
+			name:     "128-bit, nothing allowed",
+			security: models.PDFSecurity{UserPassword: "u", OwnerPassword: "o", EncryptionBits: 128},
+			want:     []string{"--encrypt", "u", "o", "128", "--use-aes=y", "--print=none", "--modify=none", "--extract=n"},
+		},
+		{
+			name:     "128-bit, printing allowed",
+			security: models.PDFSecurity{UserPassword: "u", OwnerPassword: "o", EncryptionBits: 128, AllowPrinting: true},
+			want:     []string{"--encrypt", "u", "o", "128", "--use-aes=y", "--print=full", "--modify=none", "--extract=n"},
+		},
+		{
+			name:     "unsupported key length",
+			security: models.PDFSecurity{UserPassword: "u", OwnerPassword: "o", EncryptionBits: 40, AllowCopying: true},
+			want:     []string{"--encrypt", "u", "o", "256", "--print=none", "--modify=none", "--extract=y"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encryptionArgs(&tt.security); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encryptionArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// ErrNoRedaction is returned when redaction is requested but MuPDF's
+// mutool isn't installed
+var ErrNoRedaction = errors.New("redaction engine not available")
+
+// redactScript applies Redact annotations with mutool run. MuPDF removes
+// the glyphs and image pixels under each rectangle and paints it black;
+// saving with garbage collection drops the replaced objects so the original
+// content isn't left in the file. Line art is kept: removing it needs
+// MuPDF 1.24. Rectangles are in MuPDF page space, points from the top-left
+// corner of the visible page.
+const redactScript = `var doc = new PDFDocument(scriptArgs[0]);
+var pages = JSON.parse(readFile(scriptArgs[1]));
+for (var i = 0; i < pages.length; i++) {
+	var page = doc.loadPage(pages[i].page);
+	for (var j = 0; j < pages[i].rects.length; j++) {
+		var annot = page.createAnnotation("Redact");
+		annot.setRect(pages[i].rects[j]);
+		annot.update();
+	}
+	page.applyRedactions(true, 2);
+}
+doc.save(scriptArgs[2], "garbage,compress");
+`
+
+// redactMatcher is a compiled pattern or literal with its source
+type redactMatcher struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// Redact permanently removes matching text and explicit areas, returning
+// a report of the redactions per page
+func (m *PDFManipulator) Redact(ctx context.Context, pdf []byte, pageRange string, opts *models.RedactOptions) ([]byte, []models.RedactionPage, error) {
+	if opts == nil || len(opts.Patterns)+len(opts.Literals)+len(opts.Areas) == 0 {
+		return nil, nil, fmt.Errorf("no patterns, literals or areas to redact")
+	}
+	if _, err := exec.LookPath("mutool"); err != nil {
+		return nil, nil, ErrNoRedaction
+	}
+
+	flags := ""
+	if opts.IgnoreCase {
+		flags = "(?i)"
+	}
+	var matchers []redactMatcher
+	for _, p := range opts.Patterns {
+		re, err := regexp.Compile(flags + p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		matchers = append(matchers, redactMatcher{source: p, re: re})
+	}
+	for _, l := range opts.Literals {
+		if l == "" {
+			continue
+		}
+		matchers = append(matchers, redactMatcher{source: l, re: regexp.MustCompile(flags + regexp.QuoteMeta(l))})
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "redact-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	scriptPath := filepath.Join(workDir, "redact.js")
+	rectsPath := filepath.Join(workDir, "rects.json")
+	redactedPath := filepath.Join(workDir, "redacted.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	count, err := countPages(ctx, inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	searched, err := selectPages(pageRange, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(matchers) == 0 {
+		searched = nil
+	}
+
+	// Word boxes of the searched pages and of every page with an area
+	needed := make(map[int]bool)
+	isSearched := make(map[int]bool, len(searched))
+	for _, p := range searched {
+		needed[p] = true
+		isSearched[p] = true
+	}
+	for _, a := range opts.Areas {
+		if a.Page < 1 || a.Page > count {
+			return nil, nil, fmt.Errorf("area page %d out of range (1-%d)", a.Page, count)
+		}
+		if a.Rect[2] <= 0 || a.Rect[3] <= 0 {
+			return nil, nil, fmt.Errorf("area on page %d has no size", a.Page)
+		}
+		needed[a.Page] = true
+	}
+	pages := make([]int, 0, len(needed))
+	for p := range needed {
+		pages = append(pages, p)
+	}
+	sort.Ints(pages)
+
+	boxes, err := readRotatedBoxes(ctx, inputPath, "CropBox")
+	if err != nil {
+		return nil, nil, err
+	}
+	texts, err := visibleText(ctx, inputPath, pages, boxes)
+	if err != nil {
+		return nil, nil, err
+	}
+	textByPage := make(map[int]models.PageText, len(texts))
+	for _, t := range texts {
+		textByPage[t.Page] = t
+	}
+
+	report := make(map[int]*models.RedactionPage)
+	add := func(page int, r models.Redaction) {
+		if report[page] == nil {
+			report[page] = &models.RedactionPage{Page: page}
+		}
+		report[page].Redactions = append(report[page].Redactions, r)
+	}
+	for _, p := range searched {
+		for _, r := range findRedactions(textByPage[p], matchers) {
+			add(p, r)
+		}
+	}
+	for _, a := range opts.Areas {
+		add(a.Page, models.Redaction{Rect: a.Rect})
+	}
+
+	if len(report) == 0 {
+		return pdf, []models.RedactionPage{}, nil
+	}
+
+	type pageRects struct {
+		Page  int          `json:"page"`
+		Rects [][4]float64 `json:"rects"`
+	}
+	var redacted []int
+	var input []pageRects
+	for p := range report {
+		redacted = append(redacted, p)
+	}
+	sort.Ints(redacted)
+	for _, p := range redacted {
+		height := boxes[p-1].Height
+		pr := pageRects{Page: p - 1}
+		for _, r := range report[p].Redactions {
+			x, y, w, h := r.Rect[0], r.Rect[1], r.Rect[2], r.Rect[3]
+			pr.Rects = append(pr.Rects, [4]float64{x, height - y - h, x + w, height - y})
+		}
+		input = append(input, pr)
+	}
+	rects, _ := json.Marshal(input)
+	if err := os.WriteFile(rectsPath, rects, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write redactions: %w", err)
+	}
+	if err := os.WriteFile(scriptPath, []byte(redactScript), 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write redaction script: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "mutool", "run", scriptPath, inputPath, rectsPath, redactedPath)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("redaction failed: %w - %s", err, strings.TrimSpace(output.String()))
+	}
+
+	// Make sure no match survived in the text layer and no text is left
+	// inside an area
+	after, err := visibleText(ctx, redactedPath, redacted, boxes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, t := range after {
+		if isSearched[t.Page] && len(findRedactions(t, matchers)) > 0 {
+			return nil, nil, fmt.Errorf("redaction left matching text on page %d", t.Page)
+		}
+		for _, a := range opts.Areas {
+			if a.Page == t.Page && wordsInside(t.Words, a.Rect) {
+				return nil, nil, fmt.Errorf("redaction left text inside an area on page %d", t.Page)
+			}
+		}
+	}
+
+	outputPath := redactedPath
+	if opts.Rasterize {
+		outputPath = filepath.Join(workDir, "output.pdf")
+		if err := rasterizePages(ctx, workDir, redactedPath, outputPath, redacted, count, boxes, opts.DPI); err != nil {
+			return nil, nil, err
+		}
+		for _, p := range redacted {
+			report[p].Rasterized = true
+		}
+	}
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pagesReport := make([]models.RedactionPage, 0, len(redacted))
+	for _, p := range redacted {
+		pagesReport = append(pagesReport, *report[p])
+	}
+	return result, pagesReport, nil
+}
+
+// visibleText returns the words of the given pages on the visible page,
+// the CropBox with /Rotate applied, which MuPDF and pdftoppm use too.
+// pdftotext -cropbox places words there but reports the unrotated size.
+func visibleText(ctx context.Context, pdfPath string, pages []int, boxes []pageBox) ([]models.PageText, error) {
+	texts, err := pageText(ctx, pdfPath, pages, TextModeJSON, "-cropbox")
+	if err != nil {
+		return nil, err
+	}
+	for i := range texts {
+		t := &texts[i]
+		box := boxes[t.Page-1]
+		for j := range t.Words {
+			t.Words[j].Rect[1] += box.Height - t.Height
+		}
+		t.Width, t.Height = box.Width, box.Height
+	}
+	return texts, nil
+}
+
+// wordsInside reports whether the center of any word lies in rect
+func wordsInside(words []models.TextWord, rect [4]float64) bool {
+	for _, w := range words {
+		x, y := w.Rect[0]+w.Rect[2]/2, w.Rect[1]+w.Rect[3]/2
+		if x >= rect[0] && x <= rect[0]+rect[2] && y >= rect[1] && y <= rect[1]+rect[3] {
+			return true
+		}
+	}
+	return false
+}
+
+// findRedactions matches the patterns line by line and covers every word a
+// match touches, so partial words are removed whole
+func findRedactions(page models.PageText, matchers []redactMatcher) []models.Redaction {
+	var redactions []models.Redaction
+	for start := 0; start < len(page.Words); {
+		end := start + 1
+		for end < len(page.Words) && page.Words[end].Block == page.Words[start].Block &&
+			page.Words[end].Line == page.Words[start].Line {
+			end++
+		}
+		words := page.Words[start:end]
+		start = end
+
+		// Byte offset of each word in the line text
+		var line strings.Builder
+		offsets := make([]int, len(words))
+		for i, w := range words {
+			if i > 0 {
+				line.WriteByte(' ')
+			}
+			offsets[i] = line.Len()
+			line.WriteString(w.Text)
+		}
+		text := line.String()
+
+		for _, m := range matchers {
+			for _, loc := range m.re.FindAllStringIndex(text, -1) {
+				if loc[0] == loc[1] {
+					continue
+				}
+				x0, y0 := math.Inf(1), math.Inf(1)
+				x1, y1 := math.Inf(-1), math.Inf(-1)
+				for i, w := range words {
+					if offsets[i] >= loc[1] || offsets[i]+len(w.Text) <= loc[0] {
+						continue
+					}
+					x0 = math.Min(x0, w.Rect[0])
+					y0 = math.Min(y0, w.Rect[1])
+					x1 = math.Max(x1, w.Rect[0]+w.Rect[2])
+					y1 = math.Max(y1, w.Rect[1]+w.Rect[3])
+				}
+				if math.IsInf(x0, 1) {
+					continue
+				}
+				redactions = append(redactions, models.Redaction{
+					Rect:    [4]float64{x0, y0, x1 - x0, y1 - y0},
+					Text:    text[loc[0]:loc[1]],
+					Pattern: m.source,
+				})
+			}
+		}
+	}
+	return redactions
+}
+
+// rasterizePages replaces the given pages with images of themselves, so
+// nothing but pixels remains on them
+func rasterizePages(ctx context.Context, workDir, inputPath, outputPath string, pages []int, count int, boxes []pageBox, dpi int) error {
+	rasterPath := filepath.Join(workDir, "raster.pdf")
+
+	w := newPDFWriter()
+	catalog := w.reserve()
+	pagesObj := w.reserve()
+	var kids bytes.Buffer
+	for _, p := range pages {
+		images, err := renderPages(ctx, inputPath, filepath.Join(workDir, fmt.Sprintf("raster%d", p)), "png", dpi, p, p, "-cropbox")
+		if err != nil {
+			return err
+		}
+		if len(images) != 1 {
+			return fmt.Errorf("failed to render page %d", p)
+		}
+		data, err := os.ReadFile(images[0])
+		if err != nil {
+			return err
+		}
+		img, err := w.writeImage(inspectImage(data))
+		if err != nil {
+			return err
+		}
+
+		// The image is the visible page as rendered, which keeps its size
+		width, height := pdfNumber(boxes[p-1].Width), pdfNumber(boxes[p-1].Height)
+		content := w.writeStream("", []byte(fmt.Sprintf("q %s 0 0 %s 0 0 cm /Im0 Do Q", width, height)), true)
+		page := w.reserve()
+		w.writeObject(page, fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, width, height, img, content))
+		fmt.Fprintf(&kids, "%d 0 R ", page)
+	}
+	w.writeObject(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(pages)))
+	w.writeObject(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	if err := os.WriteFile(rasterPath, w.finish(catalog), 0644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	// Splice the images in, keeping the rest of the document
+	args := []string{inputPath, "--pages"}
+	raster := make(map[int]int, len(pages))
+	for i, p := range pages {
+		raster[p] = i + 1
+	}
+	for p := 1; p <= count; {
+		if n, ok := raster[p]; ok {
+			args = append(args, rasterPath, strconv.Itoa(n))
+			p++
+			continue
+		}
+		end := p
+		for end < count && raster[end+1] == 0 {
+			end++
+		}
+		args = append(args, inputPath, fmt.Sprintf("%d-%d", p, end))
+		p = end + 1
+	}
+	args = append(args, "--", outputPath)
+
+	cmd := exec.CommandContext(ctx, "qpdf", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to replace rasterized pages: %w - %s", err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package converters
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"pdf-forge/internal/models"
+)
+
+// testTextPage has two lines in one block; the first holds a multibyte
+// word so byte and rune offsets differ
+func testTextPage() models.PageText {
+	return models.PageText{
+		Page: 1,
+		Words: []models.TextWord{
+			{Text: "Call", Rect: [4]float64{10, 700, 20, 10}},
+			{Text: "Müller", Rect: [4]float64{35, 700, 30, 10}},
+			{Text: "at", Rect: [4]float64{70, 700, 10, 10}},
+			{Text: "555-1234", Rect: [4]float64{85, 700, 40, 10}},
+			{Text: "Dr.", Rect: [4]float64{10, 680, 15, 10}, Line: 1},
+			{Text: "Smith", Rect: [4]float64{30, 680, 25, 10}, Line: 1},
+		},
+	}
+}
+
+func TestFindRedactions(t *testing.T) {
+	// Matchers are built the way Redact builds them
+	pattern := func(p string) redactMatcher {
+		return redactMatcher{source: p, re: regexp.MustCompile(p)}
+	}
+	literal := func(l string, ignoreCase bool) redactMatcher {
+		flags := ""
+		if ignoreCase {
+			flags = "(?i)"
+		}
+		return redactMatcher{source: l, re: regexp.MustCompile(flags + regexp.QuoteMeta(l))}
+	}
+
+	tests := []struct {
+		name    string
+		matcher redactMatcher
+		want    []models.Redaction
+	}{
+		{
+			name:    "partial word covers the whole word",
+			matcher: pattern(`\d{3}-`),
+			want:    []models.Redaction{{Rect: [4]float64{85, 700, 40, 10}, Text: "555-", Pattern: `\d{3}-`}},
+		},
+		{
+			name:    "match spanning two words",
+			matcher: literal("Müller at", false),
+			want:    []models.Redaction{{Rect: [4]float64{35, 700, 45, 10}, Text: "Müller at", Pattern: "Müller at"}},
+		},
+		{
+			name:    "byte offsets after a multibyte word",
+			matcher: pattern(`\bat\b`),
+			want:    []models.Redaction{{Rect: [4]float64{70, 700, 10, 10}, Text: "at", Pattern: `\bat\b`}},
+		},
+		{
+			name:    "case-insensitive literal",
+			matcher: literal("SMITH", true),
+			want:    []models.Redaction{{Rect: [4]float64{30, 680, 25, 10}, Text: "Smith", Pattern: "SMITH"}},
+		},
+		{
+			name:    "case-sensitive literal",
+			matcher: literal("SMITH", false),
+		},
+		{
+			name:    "literal with regexp characters",
+			matcher: literal("Dr.", false),
+			want:    []models.Redaction{{Rect: [4]float64{10, 680, 15, 10}, Text: "Dr.", Pattern: "Dr."}},
+		},
+		{
+			name:    "empty matches",
+			matcher: pattern(`x*`),
+		},
+		{
+			name:    "lines are not joined",
+			matcher: literal("1234 Dr.", false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findRedactions(testTextPage(), []redactMatcher{tt.matcher})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findRedactions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordsInside(t *testing.T) {
+	words := testTextPage().Words
+	tests := []struct {
+		name string
+		rect [4]float64
+		want bool
+	}{
+		{"around a word", [4]float64{80, 695, 50, 20}, true},
+		{"over a word's center only", [4]float64{100, 704, 10, 2}, true},
+		{"center on the edge", [4]float64{105, 705, 10, 10}, true},
+		{"overlapping a word's corner", [4]float64{120, 708, 20, 20}, false},
+		{"empty region", [4]float64{300, 300, 100, 100}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wordsInside(words, tt.rect); got != tt.want {
+				t.Errorf("wordsInside(%v) = %v, want %v", tt.rect, got, tt.want)
+			}
+		})
+	}
+	if wordsInside(nil, [4]float64{0, 0, 1000, 1000}) {
+		t.Error("wordsInside() = true for no words")
+	}
+}
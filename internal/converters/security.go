@@ -0,0 +1,189 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// ErrInvalidPassword is returned when a password doesn't open a PDF
+var ErrInvalidPassword = errors.New("invalid password")
+
+// ErrOwnerPasswordRequired is returned when a password opens a PDF but
+// isn't its owner password
+var ErrOwnerPasswordRequired = errors.New("owner password required")
+
+// encryptionPermissions maps qpdf --show-encryption lines to permission keys
+var encryptionPermissions = map[string]string{
+	"print low resolution":      "print_low",
+	"print high resolution":     "print_high",
+	"modify document assembly":  "modify_assembly",
+	"modify forms":              "modify_forms",
+	"modify annotations":        "modify_annotations",
+	"modify other":              "modify_other",
+	"extract for any purpose":   "extract",
+	"extract for accessibility": "extract_accessibility",
+}
+
+// Decrypt removes encryption using the user or owner password, so it
+// opens a file without checking the caller may lift its restrictions;
+// see RequireOwnerPassword. Files with only an owner password open with
+// an empty password.
+func (m *PDFManipulator) Decrypt(ctx context.Context, pdf []byte, password string) ([]byte, error) {
+	workDir, err := os.MkdirTemp(m.tempDir, "decrypt-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	if err := runQPDFWithPassword(ctx, password, inputPath, "--decrypt", "--", outputPath); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(outputPath)
+}
+
+// ChangePermissions re-encrypts a PDF with new passwords and permissions,
+// replacing any existing encryption
+func (m *PDFManipulator) ChangePermissions(ctx context.Context, pdf []byte, password string, security *models.PDFSecurity) ([]byte, error) {
+	if security == nil || security.OwnerPassword == "" {
+		return nil, fmt.Errorf("an owner password is required")
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "permissions-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	outputPath := filepath.Join(workDir, "output.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	args := append([]string{inputPath}, encryptionArgs(security)...)
+	args = append(args, "--", outputPath)
+	if err := runQPDFWithPassword(ctx, password, args...); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(outputPath)
+}
+
+// EncryptionInfo describes a PDF's encryption, nil when it isn't encrypted
+func (m *PDFManipulator) EncryptionInfo(ctx context.Context, pdf []byte, password string) (*models.EncryptionInfo, error) {
+	output, err := m.showEncryption(ctx, pdf, password)
+	if err != nil {
+		return nil, err
+	}
+	return parseEncryption(output), nil
+}
+
+// RequireOwnerPassword checks that password is the owner password of an
+// encrypted PDF, as removing or changing its restrictions needs. A user
+// password fails with ErrOwnerPasswordRequired; unencrypted files pass.
+func (m *PDFManipulator) RequireOwnerPassword(ctx context.Context, pdf []byte, password string) error {
+	output, err := m.showEncryption(ctx, pdf, password)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(output, "File is not encrypted") || strings.Contains(output, "Supplied password is owner password") {
+		return nil
+	}
+	return ErrOwnerPasswordRequired
+}
+
+// showEncryption returns the qpdf --show-encryption report for a PDF
+func (m *PDFManipulator) showEncryption(ctx context.Context, pdf []byte, password string) (string, error) {
+	workDir, err := os.MkdirTemp(m.tempDir, "encryption-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return "", fmt.Errorf("failed to write input: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "qpdf", "--password="+password, "--show-encryption", inputPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "invalid password") {
+			return "", ErrInvalidPassword
+		}
+		return "", fmt.Errorf("failed to read encryption: %w - %s", err, stderr.String())
+	}
+	return string(output), nil
+}
+
+// parseEncryption reads qpdf --show-encryption output
+func parseEncryption(output string) *models.EncryptionInfo {
+	if strings.Contains(output, "File is not encrypted") {
+		return nil
+	}
+
+	info := &models.EncryptionInfo{Permissions: make(map[string]bool)}
+	method := ""
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			if k, v, ok := strings.Cut(line, " = "); ok && strings.TrimSpace(k) == "R" {
+				info.Revision, _ = strconv.Atoi(strings.TrimSpace(v))
+			}
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if perm, ok := encryptionPermissions[key]; ok {
+			info.Permissions[perm] = value == "allowed"
+		} else if key == "stream encryption method" {
+			method = value
+		}
+	}
+
+	switch {
+	case method == "AESv3":
+		info.Method = "AES-256"
+	case method == "AESv2":
+		info.Method = "AES-128"
+	case info.Revision <= 2:
+		info.Method = "RC4-40"
+	default:
+		info.Method = "RC4-128"
+	}
+	return info
+}
+
+// runQPDFWithPassword runs qpdf, mapping a rejected password to
+// ErrInvalidPassword
+func runQPDFWithPassword(ctx context.Context, password string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "qpdf", append([]string{"--password=" + password}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Exit status 3 means success with warnings
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 3 {
+			return nil
+		}
+		if strings.Contains(stderr.String(), "invalid password") {
+			return ErrInvalidPassword
+		}
+		return fmt.Errorf("qpdf failed: %w - %s", err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"pdf-forge/internal/models"
+)
+
+// ErrNoSigner is returned when signing is requested but no certificate
+// is configured
+var ErrNoSigner = errors.New("no signing certificate configured")
+
+// Signer holds the certificate and private key used for signatures
+type Signer struct {
+	key   crypto.Signer
+	cert  *x509.Certificate
+	chain []*x509.Certificate // Intermediate certificates, embedded for validation
+}
+
+// LoadSigner reads a PKCS#12 (.p12/.pfx) keystore with its certificate chain
+func LoadSigner(path, password string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	key, cert, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keystore: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return &Signer{key: signer, cert: cert, chain: chain}, nil
+}
+
+// Name returns the signer's common name, or the full subject without one
+func (s *Signer) Name() string {
+	if s.cert.Subject.CommonName != "" {
+		return s.cert.Subject.CommonName
+	}
+	return s.cert.Subject.String()
+}
+
+// SetSigner configures the certificate used by Sign
+func (p *PDFProcessor) SetSigner(s *Signer) {
+	p.signer = s
+}
+
+// SetTimestampURL sets the default RFC 3161 timestamp authority
+func (p *PDFProcessor) SetTimestampURL(url string) {
+	p.tsaURL = url
+}
+
+// CanSign reports whether a signing certificate is configured
+func (p *PDFProcessor) CanSign() bool {
+	return p.signer != nil
+}
+
+// Signature dictionary placeholders, filled in once the file is complete
+const (
+	byteRangePlaceholder = "[0 0000000000 0000000000 0000000000]"
+	signatureFontSize    = 8.0
+)
+
+// Sign appends a PAdES signature (ETSI.CAdES.detached) as an incremental
+// update, so earlier revisions and their signatures stay valid
+func (p *PDFProcessor) Sign(pdfData []byte, opts *models.SignatureOptions) ([]byte, error) {
+	if p.signer == nil {
+		return nil, ErrNoSigner
+	}
+	if opts == nil {
+		opts = &models.SignatureOptions{}
+	}
+
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp(p.tempDir, "sign-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	target, err := readUpdateTarget(ctx, inputPath, pdfData)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := readPageObjects(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF has no pages")
+	}
+
+	pageNum := 1
+	if opts.Appearance != nil && opts.Appearance.Page != 0 {
+		pageNum = opts.Appearance.Page
+		if pageNum < 0 {
+			pageNum = len(pages) + 1 + pageNum
+		}
+		if pageNum < 1 || pageNum > len(pages) {
+			return nil, fmt.Errorf("signature page %d out of range (1-%d)", opts.Appearance.Page, len(pages))
+		}
+	}
+	pageID := pages[pageNum-1]
+
+	header, objs, err := readQPDFObjects(ctx, inputPath, pageID)
+	if err != nil {
+		return nil, err
+	}
+	page := objs["obj:"+pageID+" 0 R"].Value
+	annots, err := readArray(ctx, inputPath, page["/Annots"])
+	if err != nil {
+		return nil, err
+	}
+
+	// The AcroForm may be inline in the catalog or an object of its own
+	formID, _ := qpdfRef(target.catalog["/AcroForm"])
+	form := map[string]json.RawMessage{}
+	if formID != "" {
+		_, objs, err := readQPDFObjects(ctx, inputPath, formID)
+		if err != nil {
+			return nil, err
+		}
+		if v := objs["obj:"+formID+" 0 R"].Value; v != nil {
+			form = v
+		}
+	} else if raw, ok := target.catalog["/AcroForm"]; ok {
+		json.Unmarshal(raw, &form)
+	}
+	fields, err := readArray(ctx, inputPath, form["/Fields"])
+	if err != nil {
+		return nil, err
+	}
+	fieldName, err := signatureFieldName(ctx, inputPath, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	w := newUpdateWriter(pdfData, maxObjectID(header))
+	sigObj, widgetObj := w.reserve(), w.reserve()
+
+	rect := "[0 0 0 0]"
+	appearance := ""
+	if opts.Appearance != nil {
+		a := opts.Appearance
+		if a.Rect[2] <= 0 || a.Rect[3] <= 0 {
+			return nil, fmt.Errorf("signature appearance needs a rect with positive width and height")
+		}
+		rect = fmt.Sprintf("[%s %s %s %s]", pdfNumber(a.Rect[0]), pdfNumber(a.Rect[1]),
+			pdfNumber(a.Rect[0]+a.Rect[2]), pdfNumber(a.Rect[1]+a.Rect[3]))
+		apObj, err := p.writeSignatureAppearance(w, a, opts)
+		if err != nil {
+			return nil, err
+		}
+		appearance = fmt.Sprintf(" /AP << /N %d 0 R >>", apObj)
+	}
+
+	// Reserve room for the certificates, the signature and a timestamp token
+	tsaURL := opts.TimestampURL
+	if tsaURL == "" {
+		tsaURL = p.tsaURL
+	}
+	reserved := len(p.signer.cert.Raw) + 4096
+	for _, c := range p.signer.chain {
+		reserved += len(c.Raw)
+	}
+	if tsaURL != "" {
+		reserved += 8192
+	}
+
+	sig := fmt.Sprintf("<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /ETSI.CAdES.detached /ByteRange %s /Contents <%s> /M %s /Name %s",
+		byteRangePlaceholder, strings.Repeat("0", reserved*2), pdfTextLiteral(pdfDate(time.Now())), pdfTextLiteral(p.signer.Name()))
+	for _, entry := range []struct{ key, value string }{
+		{"/Reason", opts.Reason},
+		{"/Location", opts.Location},
+		{"/ContactInfo", opts.ContactInfo},
+	} {
+		if entry.value != "" {
+			sig += " " + entry.key + " " + pdfTextLiteral(entry.value)
+		}
+	}
+	sigStart := w.buf.Len()
+	w.writeObject(sigObj, sig+" >>")
+
+	// Print (4) and Locked (128) flags
+	w.writeObject(widgetObj, fmt.Sprintf("<< /Type /Annot /Subtype /Widget /FT /Sig /T %s /V %d 0 R /F 132 /P %s 0 R /Rect %s%s >>",
+		pdfTextLiteral(fieldName), sigObj, pageID, rect, appearance))
+
+	widgetRef := qpdfRefValue(fmt.Sprint(widgetObj))
+	page["/Annots"] = qpdfArray(append(annots, widgetRef))
+	w.writeObject(objectNumber(pageID), pdfDict(page))
+
+	// SignaturesExist (1) and AppendOnly (2)
+	form["/Fields"] = qpdfArray(append(fields, widgetRef))
+	form["/SigFlags"] = json.RawMessage("3")
+	if formID != "" {
+		w.writeObject(objectNumber(formID), pdfDict(form))
+	} else {
+		formJSON, _ := json.Marshal(form)
+		target.catalog["/AcroForm"] = formJSON
+		w.writeObject(objectNumber(target.rootID), pdfDict(target.catalog))
+	}
+
+	out := w.finishUpdate(target)
+	return embedSignature(ctx, out, sigStart, p.signer, tsaURL)
+}
+
+// embedSignature fills in the ByteRange and Contents of the signature
+// dictionary written at sigStart
+func embedSignature(ctx context.Context, out []byte, sigStart int, signer *Signer, tsaURL string) ([]byte, error) {
+	rangePos := sigStart + bytes.Index(out[sigStart:], []byte(byteRangePlaceholder))
+	contentsStart := sigStart + bytes.Index(out[sigStart:], []byte("/Contents <")) + len("/Contents ")
+	contentsEnd := contentsStart + bytes.IndexByte(out[contentsStart:], '>') + 1
+
+	byteRange := fmt.Sprintf("[0 %d %d %d]", contentsStart, contentsEnd, len(out)-contentsEnd)
+	byteRange += strings.Repeat(" ", len(byteRangePlaceholder)-len(byteRange))
+	copy(out[rangePos:], byteRange)
+
+	h := sha256.New()
+	h.Write(out[:contentsStart])
+	h.Write(out[contentsEnd:])
+	cms, err := signCMS(ctx, signer, h.Sum(nil), tsaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := hex.EncodeToString(cms)
+	if len(encoded) > contentsEnd-contentsStart-2 {
+		return nil, fmt.Errorf("signature (%d bytes) exceeds the reserved space", len(cms))
+	}
+	copy(out[contentsStart+1:], strings.ToUpper(encoded))
+	return out, nil
+}
+
+// writeSignatureAppearance draws the visible signature box: an optional
+// image on the left and the signature text beside it
+func (p *PDFProcessor) writeSignatureAppearance(w *pdfWriter, a *models.SignatureAppearance, opts *models.SignatureOptions) (int, error) {
+	width, height := a.Rect[2], a.Rect[3]
+	fontSize := a.FontSize
+	if fontSize <= 0 {
+		fontSize = signatureFontSize
+	}
+
+	text := a.Text
+	if text == "" {
+		lines := []string{
+			"Digitally signed by " + p.signer.Name(),
+			"Date: " + time.Now().Format("2006-01-02 15:04:05 -07:00"),
+		}
+		if opts.Reason != "" {
+			lines = append(lines, "Reason: "+opts.Reason)
+		}
+		if opts.Location != "" {
+			lines = append(lines, "Location: "+opts.Location)
+		}
+		text = strings.Join(lines, "\n")
+	}
+
+	var content bytes.Buffer
+	resources := ""
+	fmt.Fprintf(&content, "q\n0 0 %s %s re W n\n", pdfNumber(width), pdfNumber(height))
+
+	textX := 2.0
+	if a.Image != "" {
+		data, err := decodeImageInput(a.Image)
+		if err != nil {
+			return 0, fmt.Errorf("signature image: %w", err)
+		}
+		img := inspectImage(data)
+		switch img.Format {
+		case "jpeg", "png", "gif":
+		default:
+			return 0, fmt.Errorf("signature image must be PNG, JPEG or GIF")
+		}
+		imgObj, err := w.writeImage(img)
+		if err != nil {
+			return 0, err
+		}
+
+		slot := width * 0.4
+		scale := math.Min(slot/float64(img.Width), height/float64(img.Height))
+		drawW, drawH := float64(img.Width)*scale, float64(img.Height)*scale
+		fmt.Fprintf(&content, "q\n%s 0 0 %s %s %s cm\n", pdfNumber(drawW), pdfNumber(drawH),
+			pdfNumber((slot-drawW)/2), pdfNumber((height-drawH)/2))
+		if m := exifMatrix(img.Orientation); m != "" {
+			content.WriteString(m + " cm\n")
+		}
+		content.WriteString("/Img Do\nQ\n")
+		resources = fmt.Sprintf(" /XObject << /Img %d 0 R >>", imgObj)
+		textX = slot + 4
+	}
+
+	fontObj := w.reserve()
+	w.writeObject(fontObj, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontHelvetica))
+	leading := fontSize * 1.2
+	y := height - 2 - fontSize*fontCapHeight/1000
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&content, "BT /Helv %s Tf %s %s Td %s Tj ET\n",
+			pdfNumber(fontSize), pdfNumber(textX), pdfNumber(y), pdfTextString(line))
+		y -= leading
+	}
+	content.WriteString("Q\n")
+
+	return w.writeStream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 %s %s] /Resources << /Font << /Helv %d 0 R >>%s >>",
+		pdfNumber(width), pdfNumber(height), fontObj, resources), content.Bytes(), true), nil
+}
+
+// signatureFieldName picks the first SignatureN not used by a top-level field
+func signatureFieldName(ctx context.Context, pdfPath string, fields []json.RawMessage) (string, error) {
+	var ids []string
+	for _, f := range fields {
+		if id, ok := qpdfRef(f); ok {
+			ids = append(ids, id)
+		}
+	}
+	used := make(map[string]bool)
+	if len(ids) > 0 {
+		_, objs, err := readQPDFObjects(ctx, pdfPath, ids...)
+		if err != nil {
+			return "", err
+		}
+		for _, obj := range objs {
+			used[qpdfText(obj.Value["/T"])] = true
+		}
+	}
+	for n := 1; ; n++ {
+		if name := fmt.Sprintf("Signature%d", n); !used[name] {
+			return name, nil
+		}
+	}
+}
+
+// readArray resolves an inline or indirect array value
+func readArray(ctx context.Context, pdfPath string, raw json.RawMessage) ([]json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if id, ok := qpdfRef(raw); ok {
+		var err error
+		if raw, err = readQPDFValue(ctx, pdfPath, id); err != nil {
+			return nil, err
+		}
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("unexpected PDF array: %w", err)
+	}
+	return items, nil
+}
+
+func objectNumber(id string) int {
+	n, _ := strconv.Atoi(id)
+	return n
+}
+
+func qpdfArray(items []json.RawMessage) json.RawMessage {
+	b, _ := json.Marshal(items)
+	return b
+}
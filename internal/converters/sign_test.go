@@ -0,0 +1,234 @@
+package converters
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"pdf-forge/internal/models"
+)
+
+var oidTSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+
+// testPDF builds a minimal one-page PDF with a valid xref table
+func testPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+// newTestSigner generates a self-signed ECDSA certificate valid for the
+// next hour
+func newTestSigner(t *testing.T) *Signer {
+	return newTestCert(t, "Test Signer", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+}
+
+// newTestCert generates a self-signed ECDSA certificate valid between
+// notBefore and notAfter
+func newTestCert(t *testing.T, name string, notBefore, notAfter time.Time, usages ...x509.ExtKeyUsage) *Signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           usages,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Signer{key: key, cert: cert}
+}
+
+// newTestTSA generates a timestamp authority certificate valid for the
+// last and next day
+func newTestTSA(t *testing.T) *Signer {
+	return newTestCert(t, "Test TSA", time.Now().Add(-24*time.Hour), time.Now().Add(24*time.Hour), x509.ExtKeyUsageTimeStamping)
+}
+
+// newTSAStub answers RFC 3161 requests with a token for the requested
+// imprint stamped at genTime, signed by tsa or unsigned if tsa is nil
+func newTSAStub(t *testing.T, tsa *Signer, genTime time.Time) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req timeStampReq
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token, err := timestampTokenFor(tsa, tstInfo{
+			Version:        1,
+			Policy:         asn1.ObjectIdentifier{1, 2, 3},
+			MessageImprint: req.MessageImprint,
+			SerialNumber:   big.NewInt(1),
+			GenTime:        genTime.UTC().Truncate(time.Second),
+		})
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp, _ := asn1.Marshal(timeStampResp{TimeStampToken: asn1.RawValue{FullBytes: token}})
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// timestampTokenFor wraps a TSTInfo in a SignedData token signed by tsa
+// over the content type and message digest attributes, as RFC 3161 does
+func timestampTokenFor(tsa *Signer, tst tstInfo) ([]byte, error) {
+	info, err := asn1.Marshal(tst)
+	if err != nil {
+		return nil, err
+	}
+	econtent, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{
+			EContentType: oidTSTInfo,
+			EContent:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: econtent},
+		},
+		SignerInfos: []signerInfo{},
+	}
+	if tsa != nil {
+		digest := sha256.Sum256(info)
+		contentType, err := cmsAttr(oidAttrContentType, oidTSTInfo)
+		if err != nil {
+			return nil, err
+		}
+		messageDigest, err := cmsAttr(oidAttrMessageDigest, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		set, attrs, err := marshalAttributes([]cmsAttribute{contentType, messageDigest}, 0)
+		if err != nil {
+			return nil, err
+		}
+		hashed := sha256.Sum256(set)
+		signature, err := tsa.key.Sign(rand.Reader, hashed[:], crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		sid, err := asn1.Marshal(issuerAndSerial{Issuer: asn1.RawValue{FullBytes: tsa.cert.RawIssuer}, Serial: tsa.cert.SerialNumber})
+		if err != nil {
+			return nil, err
+		}
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: tsa.cert.Raw}
+		sd.SignerInfos = []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        attrs,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          signature,
+		}}
+	}
+	der, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der},
+	})
+}
+
+// signTestPDF signs testPDF with a fresh certificate and timestamp, and
+// returns it with a processor that trusts the certificate and the TSA
+func signTestPDF(t *testing.T) ([]byte, *PDFProcessor) {
+	t.Helper()
+	if _, err := exec.LookPath("qpdf"); err != nil {
+		t.Skip("qpdf not installed")
+	}
+	signer, tsa := newTestSigner(t), newTestTSA(t)
+	p := &PDFProcessor{tempDir: t.TempDir()}
+	p.SetSigner(signer)
+	p.SetTimestampURL(newTSAStub(t, tsa, time.Now()).URL)
+	roots := x509.NewCertPool()
+	roots.AddCert(signer.cert)
+	roots.AddCert(tsa.cert)
+	p.SetTrustStore(roots)
+
+	signed, err := p.Sign(testPDF(), &models.SignatureOptions{Reason: "Approved", Location: "Test"})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return signed, p
+}
+
+func TestSignRoundTrip(t *testing.T) {
+	signed, p := signTestPDF(t)
+
+	sigs, err := p.InspectSignatures(signed)
+	if err != nil {
+		t.Fatalf("InspectSignatures() error = %v", err)
+	}
+	if len(sigs) != 1 {
+		t.Fatalf("got %d signatures, want 1", len(sigs))
+	}
+	sig := sigs[0]
+	if sig.Error != "" {
+		t.Fatalf("signature error: %s", sig.Error)
+	}
+	if !sig.Signed || !sig.DigestValid || !sig.Trusted || !sig.CoversDocument {
+		t.Errorf("signature = %+v, want signed, valid, trusted and covering the document", sig)
+	}
+	if !sig.Timestamped || sig.SigningTime == nil {
+		t.Errorf("signature not timestamped: %+v", sig)
+	}
+	if sig.SubFilter != "ETSI.CAdES.detached" || sig.Reason != "Approved" || sig.Location != "Test" {
+		t.Errorf("signature dictionary = %+v", sig)
+	}
+	if sig.Signer != "CN=Test Signer" {
+		t.Errorf("Signer = %q, want CN=Test Signer", sig.Signer)
+	}
+}
@@ -0,0 +1,369 @@
+package converters
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pageBox is the visible area of a page as a viewer shows it, i.e. the
+// TrimBox with /Rotate applied. qpdf maps overlay pages onto that area and
+// undoes the page rotation, so stamps are drawn upright in these coordinates.
+type pageBox struct {
+	Width, Height float64
+}
+
+// readPageBoxes returns the visible box of every page
+func readPageBoxes(ctx context.Context, pdfPath string) ([]pageBox, error) {
+	return readRotatedBoxes(ctx, pdfPath, "TrimBox")
+}
+
+// readRotatedBoxes returns the size of the named page box of every page
+// with /Rotate applied
+func readRotatedBoxes(ctx context.Context, pdfPath, box string) ([]pageBox, error) {
+	count, err := countPages(ctx, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "pdfinfo", "-box", "-f", "1", "-l", strconv.Itoa(count), pdfPath)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page boxes: %w", err)
+	}
+
+	boxes := make([]pageBox, count)
+	rotations := make([]int, count)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// "Page    1 TrimBox:     0.00     0.00   612.00   792.00"
+		// "Page    1 rot:  90"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "Page" {
+			continue
+		}
+		page, err := strconv.Atoi(fields[1])
+		if err != nil || page < 1 || page > count {
+			continue
+		}
+		switch fields[2] {
+		case box + ":":
+			if len(fields) == 7 {
+				x0, _ := strconv.ParseFloat(fields[3], 64)
+				y0, _ := strconv.ParseFloat(fields[4], 64)
+				x1, _ := strconv.ParseFloat(fields[5], 64)
+				y1, _ := strconv.ParseFloat(fields[6], 64)
+				boxes[page-1] = pageBox{Width: x1 - x0, Height: y1 - y0}
+			}
+		case "rot:":
+			rotations[page-1], _ = strconv.Atoi(fields[3])
+		}
+	}
+
+	for i := range boxes {
+		if rotations[i] == 90 || rotations[i] == 270 {
+			boxes[i].Width, boxes[i].Height = boxes[i].Height, boxes[i].Width
+		}
+	}
+	return boxes, nil
+}
+
+// selectPages expands a page selection such as "1-3,5,8-z", "odd" or "even"
+// into sorted page numbers. An empty selection means every page.
+func selectPages(selection string, pageCount int) ([]int, error) {
+	selected := make(map[int]bool)
+	selection = strings.TrimSpace(selection)
+
+	switch strings.ToLower(selection) {
+	case "", "all":
+		for i := 1; i <= pageCount; i++ {
+			selected[i] = true
+		}
+	case "odd", "even":
+		start := 1
+		if strings.ToLower(selection) == "even" {
+			start = 2
+		}
+		for i := start; i <= pageCount; i += 2 {
+			selected[i] = true
+		}
+	default:
+		parsePage := func(s string) (int, error) {
+			s = strings.TrimSpace(s)
+			if s == "z" || s == "end" {
+				return pageCount, nil
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid page %q", s)
+			}
+			return n, nil
+		}
+
+		for _, part := range strings.Split(selection, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			start, end := part, part
+			if idx := strings.Index(part, "-"); idx >= 0 {
+				start, end = part[:idx], part[idx+1:]
+			}
+			first, err := parsePage(start)
+			if err != nil {
+				return nil, err
+			}
+			last, err := parsePage(end)
+			if err != nil {
+				return nil, err
+			}
+			if first > last {
+				first, last = last, first
+			}
+			for i := first; i <= last && i <= pageCount; i++ {
+				selected[i] = true
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("page selection %q matches no pages", selection)
+	}
+
+	pages := make([]int, 0, len(selected))
+	for p := range selected {
+		pages = append(pages, p)
+	}
+	sort.Ints(pages)
+	return pages, nil
+}
+
+// joinPages formats page numbers as a qpdf page range
+func joinPages(pages []int) string {
+	parts := make([]string, len(pages))
+	for i, p := range pages {
+		parts[i] = strconv.Itoa(p)
+	}
+	return strings.Join(parts, ",")
+}
+
+// applyStamp lays the pages of stampPath over (or under) the given pages
+// of inputPath, one stamp page per target page, in order
+func applyStamp(ctx context.Context, inputPath, stampPath, outputPath string, pages []int, underlay bool) error {
+	mode := "--overlay"
+	if underlay {
+		mode = "--underlay"
+	}
+
+	args := []string{inputPath, mode, stampPath, "--to=" + joinPages(pages), "--from=1-z", "--", outputPath}
+	cmd := exec.CommandContext(ctx, "qpdf", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("qpdf %s failed: %w - %s", mode[2:], err, stderr.String())
+	}
+	return nil
+}
+
+// stampWriter builds a PDF of stamp pages sharing one resource dictionary
+type stampWriter struct {
+	w         *pdfWriter
+	catalog   int
+	pagesObj  int
+	resources int
+	pageRefs  []int
+
+	fonts      map[string]int
+	xObjects   map[string]int
+	extGStates map[string]int
+}
+
+func newStampWriter() *stampWriter {
+	w := newPDFWriter()
+	return &stampWriter{
+		w:          w,
+		catalog:    w.reserve(),
+		pagesObj:   w.reserve(),
+		resources:  w.reserve(),
+		fonts:      make(map[string]int),
+		xObjects:   make(map[string]int),
+		extGStates: make(map[string]int),
+	}
+}
+
+// font returns the resource name of a standard 14 font
+func (s *stampWriter) font(baseFont string) string {
+	name := "F" + strings.ReplaceAll(baseFont, "-", "")
+	if _, ok := s.fonts[name]; !ok {
+		obj := s.w.reserve()
+		s.w.writeObject(obj, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", baseFont))
+		s.fonts[name] = obj
+	}
+	return name
+}
+
+// opacity returns the resource name of a graphics state with the given alpha
+func (s *stampWriter) opacity(alpha float64) string {
+	name := "GS" + strings.ReplaceAll(pdfNumber(alpha*100), ".", "_")
+	if _, ok := s.extGStates[name]; !ok {
+		obj := s.w.reserve()
+		s.w.writeObject(obj, fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s >>", pdfNumber(alpha), pdfNumber(alpha)))
+		s.extGStates[name] = obj
+	}
+	return name
+}
+
+// image embeds an image once and returns its resource name
+func (s *stampWriter) image(key string, img *imageInfo) (string, error) {
+	name := "Im" + key
+	if _, ok := s.xObjects[name]; !ok {
+		obj, err := s.w.writeImage(img)
+		if err != nil {
+			return "", err
+		}
+		s.xObjects[name] = obj
+	}
+	return name, nil
+}
+
+// addPage appends a page with the given content stream
+func (s *stampWriter) addPage(box pageBox, content []byte) {
+	contentObj := s.w.writeStream("", content, true)
+	pageObj := s.w.reserve()
+	s.w.writeObject(pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+		s.pagesObj, pdfNumber(box.Width), pdfNumber(box.Height), s.resources, contentObj))
+	s.pageRefs = append(s.pageRefs, pageObj)
+}
+
+func (s *stampWriter) finish() []byte {
+	resourceDict := func(entries map[string]int) string {
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString("<< ")
+		for _, name := range names {
+			fmt.Fprintf(&b, "/%s %d 0 R ", name, entries[name])
+		}
+		b.WriteString(">>")
+		return b.String()
+	}
+	s.w.writeObject(s.resources, fmt.Sprintf("<< /Font %s /XObject %s /ExtGState %s >>",
+		resourceDict(s.fonts), resourceDict(s.xObjects), resourceDict(s.extGStates)))
+
+	var kids strings.Builder
+	for _, ref := range s.pageRefs {
+		fmt.Fprintf(&kids, "%d 0 R ", ref)
+	}
+	s.w.writeObject(s.pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(s.pageRefs)))
+	s.w.writeObject(s.catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", s.pagesObj))
+	return s.w.finish(s.catalog)
+}
+
+// Standard 14 fonts used for stamps, with their WinAnsi glyph widths
+// (1/1000 em) for printable ASCII; other characters use the average width
+const (
+	fontHelvetica     = "Helvetica"
+	fontHelveticaBold = "Helvetica-Bold"
+)
+
+var standardFontWidths = map[string][95]int{
+	fontHelvetica: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	fontHelveticaBold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+// fontCapHeight is the cap height of Helvetica in 1/1000 em
+const fontCapHeight = 718
+
+// textWidth measures text in points for a standard font
+func textWidth(font, text string, size float64) float64 {
+	widths := standardFontWidths[font]
+	total := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			total += widths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfTextString encodes text as a WinAnsi literal string. Characters
+// outside Latin-1 can't be shown by the standard fonts and become '?'.
+func pdfTextString(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// namedColors covers the color names accepted besides hex values
+var namedColors = map[string][3]float64{
+	"black": {0, 0, 0},
+	"white": {1, 1, 1},
+	"gray":  {0.5, 0.5, 0.5},
+	"grey":  {0.5, 0.5, 0.5},
+	"red":   {0.8, 0, 0},
+	"green": {0, 0.5, 0},
+	"blue":  {0, 0, 0.8},
+}
+
+// parseColor reads #RRGGBB, #RGB or a basic color name into RGB 0-1
+func parseColor(value string, fallback [3]float64) [3]float64 {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if c, ok := namedColors[value]; ok {
+		return c
+	}
+
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return fallback
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return [3]float64{
+		float64(v>>16&0xFF) / 255,
+		float64(v>>8&0xFF) / 255,
+		float64(v&0xFF) / 255,
+	}
+}
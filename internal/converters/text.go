@@ -0,0 +1,172 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pdf-forge/internal/models"
+)
+
+// Text extraction modes
+const (
+	TextModePlain  = "plain"  // Reading order
+	TextModeLayout = "layout" // Physical layout kept with spaces
+	TextModeJSON   = "json"   // Words with bounding boxes
+)
+
+// bboxPage is a page of pdftotext -bbox-layout output. Coordinates are
+// in points from the top-left corner.
+type bboxPage struct {
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+	Blocks []struct {
+		Lines []struct {
+			Words []struct {
+				XMin float64 `xml:"xMin,attr"`
+				YMin float64 `xml:"yMin,attr"`
+				XMax float64 `xml:"xMax,attr"`
+				YMax float64 `xml:"yMax,attr"`
+				Text string  `xml:",chardata"`
+			} `xml:"word"`
+		} `xml:"line"`
+	} `xml:"flow>block"`
+}
+
+// ExtractText returns the text of the selected pages (all when pageRange
+// is empty) in the given mode
+func (m *PDFManipulator) ExtractText(ctx context.Context, pdf []byte, pageRange, mode string) ([]models.PageText, error) {
+	if mode == "" {
+		mode = TextModePlain
+	}
+	if mode != TextModePlain && mode != TextModeLayout && mode != TextModeJSON {
+		return nil, fmt.Errorf("unknown text mode %q", mode)
+	}
+
+	workDir, err := os.MkdirTemp(m.tempDir, "text-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	count, err := countPages(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectPages(pageRange, count)
+	if err != nil {
+		return nil, err
+	}
+	return pageText(ctx, inputPath, pages, mode)
+}
+
+// pageText runs pdftotext over the given sorted pages, with any extra
+// pdftotext arguments
+func pageText(ctx context.Context, inputPath string, pages []int, mode string, extra ...string) ([]models.PageText, error) {
+	// One pdftotext run covers the span of the selection
+	first, last := pages[0], pages[len(pages)-1]
+	args := []string{"-enc", "UTF-8", "-f", strconv.Itoa(first), "-l", strconv.Itoa(last)}
+	switch mode {
+	case TextModeLayout:
+		args = append(args, "-layout")
+	case TextModeJSON:
+		args = append(args, "-bbox-layout")
+	}
+	args = append(args, extra...)
+	args = append(args, inputPath, "-")
+
+	cmd := exec.CommandContext(ctx, "pdftotext", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("text extraction failed: %w - %s", err, stderr.String())
+	}
+
+	var span []models.PageText
+	if mode == TextModeJSON {
+		span, err = parseBBoxLayout(output)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// pdftotext ends every page with a form feed
+		for _, text := range strings.Split(string(output), "\f") {
+			span = append(span, models.PageText{Text: text})
+		}
+	}
+
+	result := make([]models.PageText, 0, len(pages))
+	for _, p := range pages {
+		if i := p - first; i < len(span) {
+			page := span[i]
+			page.Page = p
+			result = append(result, page)
+		}
+	}
+	return result, nil
+}
+
+// parseBBoxLayout reads the XHTML written by pdftotext -bbox-layout,
+// turning word boxes into x, y, width, height from the bottom-left corner
+func parseBBoxLayout(output []byte) ([]models.PageText, error) {
+	d := xml.NewDecoder(bytes.NewReader(output))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var pages []models.PageText
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unexpected pdftotext output: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+
+		var bp bboxPage
+		if err := d.DecodeElement(&bp, &start); err != nil {
+			return nil, fmt.Errorf("unexpected pdftotext output: %w", err)
+		}
+		page := models.PageText{Width: bp.Width, Height: bp.Height}
+		var lines []string
+		for b, block := range bp.Blocks {
+			if b > 0 {
+				lines = append(lines, "")
+			}
+			for l, line := range block.Lines {
+				words := make([]string, 0, len(line.Words))
+				for _, w := range line.Words {
+					words = append(words, w.Text)
+					page.Words = append(page.Words, models.TextWord{
+						Text:  w.Text,
+						Rect:  [4]float64{w.XMin, bp.Height - w.YMax, w.XMax - w.XMin, w.YMax - w.YMin},
+						Block: b,
+						Line:  l,
+					})
+				}
+				lines = append(lines, strings.Join(words, " "))
+			}
+		}
+		page.Text = strings.Join(lines, "\n")
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
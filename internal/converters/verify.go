@@ -0,0 +1,547 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha1" // Register SHA-1 for adbe.pkcs7.sha1 and legacy signatures
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pdf-forge/internal/models"
+)
+
+var (
+	oidSHA1      = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA384    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidRSAPSS    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	digestHashes = map[string]crypto.Hash{
+		oidSHA1.String():   crypto.SHA1,
+		oidSHA256.String(): crypto.SHA256,
+		oidSHA384.String(): crypto.SHA384,
+		oidSHA512.String(): crypto.SHA512,
+	}
+)
+
+// LoadTrustStore reads PEM root certificates from a file or from every
+// .pem, .crt and .cer file in a directory
+func LoadTrustStore(path string) (*x509.CertPool, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to read trust store: %w", err)
+	} else if info.IsDir() {
+		files = nil
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trust store: %w", err)
+		}
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".pem", ".crt", ".cer":
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	pool := x509.NewCertPool()
+	count := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trust store: %w", err)
+		}
+		for {
+			var block *pem.Block
+			if block, data = pem.Decode(data); block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate in %s: %w", file, err)
+			}
+			pool.AddCert(cert)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// SetTrustStore sets the roots signatures are validated against; nil
+// uses the system roots
+func (p *PDFProcessor) SetTrustStore(pool *x509.CertPool) {
+	p.trustStore = pool
+}
+
+// InspectSignatures lists the signature fields of a PDF and verifies
+// each signature's digest and certificate chain
+func (p *PDFProcessor) InspectSignatures(pdfData []byte) ([]models.SignatureInfo, error) {
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp(p.tempDir, "verify-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	objs, err := readAllQPDFObjects(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var sigs []models.SignatureInfo
+	for _, obj := range objs {
+		if obj.Value == nil || qpdfName(fieldType(objs, obj.Value)) != "Sig" {
+			continue
+		}
+		// Widgets of a shared field inherit /FT but have no /T of their own
+		if _, ok := obj.Value["/T"]; !ok {
+			continue
+		}
+		info := models.SignatureInfo{Field: fieldName(objs, obj.Value)}
+		if ref, ok := qpdfRefString(obj.Value["/V"]); ok {
+			if sig := objs["obj:"+ref].Value; sig != nil {
+				info.Signed = true
+				p.verifySignature(pdfData, sig, &info)
+			}
+		}
+		sigs = append(sigs, info)
+	}
+
+	// Order by position in the file, which is signing order
+	sort.SliceStable(sigs, func(i, j int) bool {
+		if len(sigs[i].ByteRange) == 4 && len(sigs[j].ByteRange) == 4 {
+			return sigs[i].ByteRange[2] < sigs[j].ByteRange[2]
+		}
+		return sigs[i].Field < sigs[j].Field
+	})
+	return sigs, nil
+}
+
+// verifySignature checks one signature dictionary, recording problems
+// in info.Error rather than failing the whole inspection
+func (p *PDFProcessor) verifySignature(pdfData []byte, sig map[string]json.RawMessage, info *models.SignatureInfo) {
+	info.SubFilter = qpdfName(sig["/SubFilter"])
+	info.Reason = qpdfText(sig["/Reason"])
+	info.Location = qpdfText(sig["/Location"])
+	if t, ok := parsePDFDate(qpdfText(sig["/M"])); ok {
+		info.SigningTime = &t
+	}
+
+	var br []int64
+	if err := json.Unmarshal(sig["/ByteRange"], &br); err != nil || len(br) != 4 {
+		info.Error = "signature has no valid /ByteRange"
+		return
+	}
+	info.ByteRange = br
+	size := int64(len(pdfData))
+	if br[0] != 0 || br[1] < 0 || br[2] < br[1] || br[3] < 0 || br[2]+br[3] > size {
+		info.Error = "signature /ByteRange lies outside the file"
+		return
+	}
+	info.CoversDocument = br[2]+br[3] == size
+
+	// The gap between the two ranges must be this signature's own hex
+	// /Contents string, not some other string left out of the signed bytes
+	gap := pdfData[br[1]:br[2]]
+	if len(gap) < 2 || gap[0] != '<' || gap[len(gap)-1] != '>' {
+		info.Error = "signature /Contents is not a hex string"
+		return
+	}
+	der, err := hex.DecodeString(string(gap[1 : len(gap)-1]))
+	if err != nil {
+		info.Error = "signature /Contents is not a hex string"
+		return
+	}
+	if contents, ok := qpdfBytes(sig["/Contents"]); !ok || !bytes.Equal(der, contents) {
+		info.Error = "signature /ByteRange does not exclude its /Contents"
+		return
+	}
+	signed := make([]byte, 0, br[1]+br[3])
+	signed = append(signed, pdfData[:br[1]]...)
+	signed = append(signed, pdfData[br[2]:br[2]+br[3]]...)
+
+	if err := p.verifyCMS(der, signed, info); err != nil {
+		info.Error = err.Error()
+	}
+}
+
+// verifyCMS verifies a detached (or adbe.pkcs7.sha1) CMS signature over
+// the signed bytes and validates the signer's certificate chain
+func (p *PDFProcessor) verifyCMS(der, signed []byte, info *models.SignatureInfo) error {
+	// Padding after the DER structure is ignored
+	sd, err := parseSignedData(der)
+	if err != nil {
+		return fmt.Errorf("invalid CMS signature: %w", err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return fmt.Errorf("CMS signature has no signer")
+	}
+	si := sd.SignerInfos[0]
+	certs, err := cmsCertificates(sd)
+	if err != nil {
+		return fmt.Errorf("invalid certificates in signature: %w", err)
+	}
+	cert := findSignerCertificate(si.SID, certs)
+	if cert == nil {
+		return fmt.Errorf("signer certificate not included in the signature")
+	}
+	info.Signer = cert.Subject.String()
+	info.Issuer = cert.Issuer.String()
+
+	hash, ok := digestHashes[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("unsupported digest algorithm %s", si.DigestAlgorithm.Algorithm)
+	}
+
+	// adbe.pkcs7.sha1 signs a SHA-1 digest of the document carried as content
+	content := signed
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		var embedded []byte
+		if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &embedded); err != nil {
+			return fmt.Errorf("invalid CMS content: %w", err)
+		}
+		h := crypto.SHA1.New()
+		h.Write(signed)
+		if !bytes.Equal(embedded, h.Sum(nil)) {
+			return fmt.Errorf("document digest does not match")
+		}
+		content = embedded
+	}
+
+	if err := verifySignerInfo(si, cert, hash, content, info); err != nil {
+		return err
+	}
+	info.DigestValid = true
+
+	// Only a verified timestamp may stand in for the current time; /M and
+	// the signingTime attribute are whatever the signer claims
+	validAt := time.Now()
+	t, err := p.verifyTimestamp(si)
+	if err != nil {
+		return err
+	}
+	if t != nil {
+		info.SigningTime, info.Timestamped = t, true
+		validAt = *t
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         p.trustStore,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   validAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, c := range certs {
+		if c != cert {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("certificate not trusted: %w", err)
+	}
+	info.Trusted = true
+	return nil
+}
+
+// verifySignerInfo checks that a SignerInfo's message digest matches the
+// content and its signature value verifies with cert. A signingTime
+// attribute is recorded in info when info isn't nil.
+func verifySignerInfo(si signerInfo, cert *x509.Certificate, hash crypto.Hash, content []byte, info *models.SignatureInfo) error {
+	message := content
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		// Signed attributes are signed as a SET, not with their implicit tag
+		message = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+		var attrs []cmsAttribute
+		if _, err := asn1.UnmarshalWithParams(message, &attrs, "set"); err != nil {
+			return fmt.Errorf("invalid signed attributes: %w", err)
+		}
+		h := hash.New()
+		h.Write(content)
+		digest, found := h.Sum(nil), false
+		for _, a := range attrs {
+			if len(a.Values) == 0 {
+				continue
+			}
+			switch {
+			case a.Type.Equal(oidAttrMessageDigest):
+				var md []byte
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &md); err != nil || !bytes.Equal(md, digest) {
+					return fmt.Errorf("document digest does not match")
+				}
+				found = true
+			case a.Type.Equal(oidAttrSigningTime) && info != nil:
+				var t time.Time
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &t); err == nil {
+					info.SigningTime = &t
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("signed attributes have no message digest")
+		}
+	}
+
+	algo := signatureAlgorithm(cert, hash, si.SignatureAlgorithm.Algorithm)
+	if err := cert.CheckSignature(algo, message, si.Signature); err != nil {
+		return fmt.Errorf("signature value does not verify: %w", err)
+	}
+	return nil
+}
+
+// verifyTimestamp checks the RFC 3161 timestamp token of a signature, if
+// it has one: the token must cover the signature value, be signed by its
+// timestamp authority and that certificate must chain to the trust store
+// at the stamped time. It returns the stamped time, nil without a token.
+func (p *PDFProcessor) verifyTimestamp(si signerInfo) (*time.Time, error) {
+	token := timestampToken(si)
+	if token == nil {
+		return nil, nil
+	}
+	sd, err := parseSignedData(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	var content []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(content, &tst); err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+
+	imprintHash, ok := digestHashes[tst.MessageImprint.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported timestamp digest algorithm %s", tst.MessageImprint.HashAlgorithm.Algorithm)
+	}
+	h := imprintHash.New()
+	h.Write(si.Signature)
+	if !bytes.Equal(h.Sum(nil), tst.MessageImprint.HashedMessage) {
+		return nil, fmt.Errorf("timestamp token does not match the signature")
+	}
+
+	if len(sd.SignerInfos) == 0 {
+		return nil, fmt.Errorf("timestamp token is not signed")
+	}
+	tsi := sd.SignerInfos[0]
+	certs, err := cmsCertificates(sd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificates in timestamp token: %w", err)
+	}
+	tsa := findSignerCertificate(tsi.SID, certs)
+	if tsa == nil {
+		return nil, fmt.Errorf("timestamp authority certificate not included in the token")
+	}
+	tokenHash, ok := digestHashes[tsi.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported timestamp digest algorithm %s", tsi.DigestAlgorithm.Algorithm)
+	}
+	if err := verifySignerInfo(tsi, tsa, tokenHash, content, nil); err != nil {
+		return nil, fmt.Errorf("timestamp token: %w", err)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         p.trustStore,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   tst.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	for _, c := range certs {
+		if c != tsa {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+	if _, err := tsa.Verify(opts); err != nil {
+		return nil, fmt.Errorf("timestamp authority not trusted: %w", err)
+	}
+	t := tst.GenTime
+	return &t, nil
+}
+
+// timestampToken returns the timestamp token among a SignerInfo's
+// unsigned attributes, nil if there is none
+func timestampToken(si signerInfo) []byte {
+	if len(si.UnsignedAttrs.FullBytes) == 0 {
+		return nil
+	}
+	var attrs []cmsAttribute
+	set := append([]byte{0x31}, si.UnsignedAttrs.FullBytes[1:]...)
+	if _, err := asn1.UnmarshalWithParams(set, &attrs, "set"); err != nil {
+		return nil
+	}
+	for _, a := range attrs {
+		if a.Type.Equal(oidAttrTimestampToken) && len(a.Values) > 0 {
+			return a.Values[0].FullBytes
+		}
+	}
+	return nil
+}
+
+// findSignerCertificate matches a SignerIdentifier, either issuer and
+// serial number or a [0] subject key identifier
+func findSignerCertificate(sid asn1.RawValue, certs []*x509.Certificate) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil
+	}
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// signatureAlgorithm picks the x509 algorithm for a key type and digest
+func signatureAlgorithm(cert *x509.Certificate, hash crypto.Hash, sigOID asn1.ObjectIdentifier) x509.SignatureAlgorithm {
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if sigOID.Equal(oidRSAPSS) {
+			switch hash {
+			case crypto.SHA384:
+				return x509.SHA384WithRSAPSS
+			case crypto.SHA512:
+				return x509.SHA512WithRSAPSS
+			}
+			return x509.SHA256WithRSAPSS
+		}
+		return map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA1:   x509.SHA1WithRSA,
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		}[hash]
+	case *ecdsa.PublicKey:
+		return map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA1:   x509.ECDSAWithSHA1,
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		}[hash]
+	case ed25519.PublicKey:
+		return x509.PureEd25519
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+// readAllQPDFObjects returns every object of a PDF without stream data
+func readAllQPDFObjects(ctx context.Context, pdfPath string) (map[string]qpdfObject, error) {
+	cmd := exec.CommandContext(ctx, "qpdf", "--json=2", "--json-key=qpdf", "--json-stream-data=none", pdfPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	// Exit status 3 means a damaged file was recovered; its signatures
+	// are still reported, with the damage showing in their byte ranges
+	if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == 3) {
+		return nil, fmt.Errorf("failed to read PDF objects: %w - %s", err, stderr.String())
+	}
+	var doc qpdfJSON
+	if err := json.Unmarshal(output, &doc); err != nil || len(doc.QPDF) != 2 {
+		return nil, fmt.Errorf("unexpected qpdf JSON output")
+	}
+	var objs map[string]qpdfObject
+	if err := json.Unmarshal(doc.QPDF[1], &objs); err != nil {
+		return nil, fmt.Errorf("unexpected qpdf JSON objects: %w", err)
+	}
+	return objs, nil
+}
+
+// fieldType returns a field's /FT, inherited from its parents
+func fieldType(objs map[string]qpdfObject, field map[string]json.RawMessage) json.RawMessage {
+	for depth := 0; field != nil && depth < 32; depth++ {
+		if ft, ok := field["/FT"]; ok {
+			return ft
+		}
+		ref, ok := qpdfRefString(field["/Parent"])
+		if !ok {
+			break
+		}
+		field = objs["obj:"+ref].Value
+	}
+	return nil
+}
+
+// fieldName builds the fully qualified name, parent names joined by dots
+func fieldName(objs map[string]qpdfObject, field map[string]json.RawMessage) string {
+	var parts []string
+	for depth := 0; field != nil && depth < 32; depth++ {
+		if t := qpdfText(field["/T"]); t != "" {
+			parts = append([]string{t}, parts...)
+		}
+		ref, ok := qpdfRefString(field["/Parent"])
+		if !ok {
+			break
+		}
+		field = objs["obj:"+ref].Value
+	}
+	return strings.Join(parts, ".")
+}
+
+// qpdfRefString returns an indirect reference with its generation, e.g. "12 0 R"
+func qpdfRefString(raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.HasSuffix(s, " R") {
+		return "", false
+	}
+	return s, true
+}
+
+// qpdfName returns a name value without its leading slash
+func qpdfName(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.HasPrefix(s, "/") {
+		return ""
+	}
+	return s[1:]
+}
+
+// qpdfBytes returns the bytes of a string value. qpdf writes binary
+// strings as "b:" hex and text as "u:" UTF-8, which is exact for ASCII.
+func qpdfBytes(raw json.RawMessage) ([]byte, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return nil, false
+	}
+	switch {
+	case strings.HasPrefix(s, "b:"):
+		b, err := hex.DecodeString(s[2:])
+		return b, err == nil
+	case strings.HasPrefix(s, "u:"):
+		return []byte(s[2:]), true
+	}
+	return nil, false
+}
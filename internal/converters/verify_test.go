@@ -0,0 +1,169 @@
+package converters
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pdf-forge/internal/models"
+)
+
+func TestInspectSignaturesDetectsChanges(t *testing.T) {
+	signed, p := signTestPDF(t)
+
+	tests := []struct {
+		name      string
+		mutate    func([]byte) []byte
+		wantValid bool
+		wantCover bool
+		wantError string
+	}{
+		{
+			name:      "unchanged",
+			mutate:    func(b []byte) []byte { return b },
+			wantValid: true,
+			wantCover: true,
+		},
+		{
+			name:      "tampered",
+			mutate:    func(b []byte) []byte { return bytes.Replace(b, []byte("612"), []byte("613"), 1) },
+			wantError: "document digest does not match",
+		},
+		{
+			name:      "truncated",
+			mutate:    func(b []byte) []byte { return b[:len(b)-8] },
+			wantError: "outside the file",
+		},
+		{
+			name:      "appended to",
+			mutate:    func(b []byte) []byte { return append(b, "% appended\n"...) },
+			wantValid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.mutate(append([]byte(nil), signed...))
+			sigs, err := p.InspectSignatures(data)
+			if err != nil {
+				t.Fatalf("InspectSignatures() error = %v", err)
+			}
+			if len(sigs) != 1 {
+				t.Fatalf("got %d signatures, want 1", len(sigs))
+			}
+			sig := sigs[0]
+			if tt.wantError != "" {
+				if !strings.Contains(sig.Error, tt.wantError) || sig.DigestValid {
+					t.Errorf("signature = %+v, want invalid with error %q", sig, tt.wantError)
+				}
+				return
+			}
+			if sig.Error != "" || sig.DigestValid != tt.wantValid || sig.CoversDocument != tt.wantCover {
+				t.Errorf("signature = %+v, want digest_valid %v and covers_document %v", sig, tt.wantValid, tt.wantCover)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureChecksContents(t *testing.T) {
+	signed, p := signTestPDF(t)
+
+	path := filepath.Join(t.TempDir(), "signed.pdf")
+	if err := os.WriteFile(path, signed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	objs, err := readAllQPDFObjects(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig map[string]json.RawMessage
+	for _, obj := range objs {
+		if qpdfName(obj.Value["/Type"]) == "Sig" {
+			sig = obj.Value
+		}
+	}
+	if sig == nil {
+		t.Fatal("no signature dictionary found")
+	}
+
+	// A /ByteRange whose gap holds a different string than the dictionary's
+	// /Contents must not verify, even though the gap's signature is valid
+	sig["/Contents"] = json.RawMessage(`"b:3000"`)
+	var info models.SignatureInfo
+	p.verifySignature(signed, sig, &info)
+	if info.DigestValid || !strings.Contains(info.Error, "/Contents") {
+		t.Errorf("signature = %+v, want a /Contents mismatch", info)
+	}
+}
+
+func TestVerifyCMSValidityTime(t *testing.T) {
+	// The signer's certificate expired yesterday; it was valid a day before
+	expired := newTestCert(t, "Expired Signer", time.Now().Add(-72*time.Hour), time.Now().Add(-24*time.Hour))
+	whileValid := time.Now().Add(-48 * time.Hour)
+	tsa := newTestCert(t, "Test TSA", whileValid.Add(-time.Hour), time.Now().Add(time.Hour), x509.ExtKeyUsageTimeStamping)
+	forger := newTestCert(t, "Test TSA", whileValid.Add(-time.Hour), time.Now().Add(time.Hour), x509.ExtKeyUsageTimeStamping)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(expired.cert)
+	roots.AddCert(tsa.cert)
+	p := &PDFProcessor{trustStore: roots}
+
+	tests := []struct {
+		name            string
+		tsa             string
+		claimed         *time.Time
+		wantTrusted     bool
+		wantTimestamped bool
+		wantError       string
+	}{
+		{
+			name:      "no timestamp",
+			wantError: "certificate not trusted",
+		},
+		{
+			name:      "backdated signing time",
+			claimed:   &whileValid,
+			wantError: "certificate not trusted",
+		},
+		{
+			name:            "trusted timestamp",
+			tsa:             newTSAStub(t, tsa, whileValid).URL,
+			wantTrusted:     true,
+			wantTimestamped: true,
+		},
+		{
+			name:      "untrusted timestamp authority",
+			tsa:       newTSAStub(t, forger, whileValid).URL,
+			wantError: "timestamp authority not trusted",
+		},
+		{
+			name:      "unsigned timestamp token",
+			tsa:       newTSAStub(t, nil, whileValid).URL,
+			wantError: "timestamp token is not signed",
+		},
+	}
+	content := []byte("signed bytes")
+	digest := sha256.Sum256(content)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := signCMS(context.Background(), expired, digest[:], tt.tsa)
+			if err != nil {
+				t.Fatalf("signCMS() error = %v", err)
+			}
+			info := models.SignatureInfo{SigningTime: tt.claimed}
+			err = p.verifyCMS(der, content, &info)
+			if tt.wantError == "" && err != nil || tt.wantError != "" && (err == nil || !strings.Contains(err.Error(), tt.wantError)) {
+				t.Errorf("verifyCMS() error = %v, want %q", err, tt.wantError)
+			}
+			if !info.DigestValid || info.Trusted != tt.wantTrusted || info.Timestamped != tt.wantTimestamped {
+				t.Errorf("signature = %+v, want trusted %v and timestamped %v", info, tt.wantTrusted, tt.wantTimestamped)
+			}
+		})
+	}
+}
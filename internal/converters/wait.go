@@ -0,0 +1,174 @@
+package converters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"pdf-forge/internal/models"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	defaultIdleTime    = 500 * time.Millisecond
+)
+
+// readinessWaiter decides when a loaded page is ready to be printed
+type readinessWaiter struct {
+	cfg      *models.WaitFor
+	fallback time.Duration
+
+	// Network idle tracking
+	mu           sync.Mutex
+	inflight     map[network.RequestID]struct{}
+	lastActivity time.Time
+}
+
+// newReadinessWaiter prepares the configured wait strategy for a tab.
+// It must be called before the first chromedp.Run on taskCtx so the
+// network listener sees every request. Without a wait_for option the
+// legacy fixed delay is used.
+func newReadinessWaiter(taskCtx context.Context, opts *models.PDFOptions, fallback time.Duration) *readinessWaiter {
+	w := &readinessWaiter{fallback: fallback}
+	if opts != nil {
+		w.cfg = opts.WaitFor
+	}
+
+	if w.cfg != nil && w.cfg.Strategy == models.WaitNetworkIdle {
+		w.inflight = make(map[network.RequestID]struct{})
+		w.lastActivity = time.Now()
+		chromedp.ListenTarget(taskCtx, w.trackNetwork)
+	}
+
+	return w
+}
+
+// Wait returns the action that blocks until the page is ready
+func (w *readinessWaiter) Wait() chromedp.Action {
+	if w.cfg == nil {
+		return chromedp.Sleep(w.fallback)
+	}
+
+	timeout := defaultWaitTimeout
+	if w.cfg.Timeout > 0 {
+		timeout = time.Duration(w.cfg.Timeout) * time.Millisecond
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		err := w.run(waitCtx)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, chromedp.ErrPollingTimeout) {
+			return fmt.Errorf("wait_for %s: %s not satisfied within %s", w.cfg.Strategy, w.describe(), timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("wait_for %s: %w", w.cfg.Strategy, err)
+		}
+		return nil
+	})
+}
+
+func (w *readinessWaiter) run(ctx context.Context) error {
+	switch w.cfg.Strategy {
+	case models.WaitSelector:
+		if w.cfg.Selector == "" {
+			return fmt.Errorf("selector is required")
+		}
+		return chromedp.WaitReady(w.cfg.Selector, chromedp.ByQuery).Do(ctx)
+
+	case models.WaitExpression:
+		if w.cfg.Expression == "" {
+			return fmt.Errorf("expression is required")
+		}
+		deadline, _ := ctx.Deadline()
+		return chromedp.Poll(w.cfg.Expression, nil,
+			chromedp.WithPollingInterval(100*time.Millisecond),
+			chromedp.WithPollingTimeout(time.Until(deadline)),
+		).Do(ctx)
+
+	case models.WaitFonts:
+		var ready bool
+		return chromedp.Evaluate(`document.fonts.ready.then(() => true)`, &ready,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+				return p.WithAwaitPromise(true)
+			},
+		).Do(ctx)
+
+	case models.WaitNetworkIdle:
+		return w.waitNetworkIdle(ctx)
+
+	case models.WaitDelay:
+		return chromedp.Sleep(time.Duration(w.cfg.Delay) * time.Millisecond).Do(ctx)
+
+	default:
+		return fmt.Errorf("unknown strategy %q", w.cfg.Strategy)
+	}
+}
+
+// describe names the condition for error messages
+func (w *readinessWaiter) describe() string {
+	switch w.cfg.Strategy {
+	case models.WaitSelector:
+		return fmt.Sprintf("selector %q", w.cfg.Selector)
+	case models.WaitExpression:
+		return fmt.Sprintf("expression %q", w.cfg.Expression)
+	case models.WaitFonts:
+		return "document.fonts.ready"
+	case models.WaitNetworkIdle:
+		w.mu.Lock()
+		pending := len(w.inflight)
+		w.mu.Unlock()
+		return fmt.Sprintf("network idle (%d requests still pending)", pending)
+	default:
+		return "condition"
+	}
+}
+
+func (w *readinessWaiter) trackNetwork(ev interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch e := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		w.inflight[e.RequestID] = struct{}{}
+	case *network.EventLoadingFinished:
+		delete(w.inflight, e.RequestID)
+	case *network.EventLoadingFailed:
+		delete(w.inflight, e.RequestID)
+	default:
+		return
+	}
+	w.lastActivity = time.Now()
+}
+
+func (w *readinessWaiter) waitNetworkIdle(ctx context.Context) error {
+	idle := defaultIdleTime
+	if w.cfg.IdleTime > 0 {
+		idle = time.Duration(w.cfg.IdleTime) * time.Millisecond
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		w.mu.Lock()
+		quiet := len(w.inflight) == 0 && time.Since(w.lastActivity) >= idle
+		w.mu.Unlock()
+		if quiet {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
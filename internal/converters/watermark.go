@@ -0,0 +1,175 @@
+package converters
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"pdf-forge/internal/models"
+)
+
+// Watermark positions and layers
+const (
+	WatermarkCenter      = "center"
+	WatermarkTopLeft     = "top_left"
+	WatermarkTopRight    = "top_right"
+	WatermarkBottomLeft  = "bottom_left"
+	WatermarkBottomRight = "bottom_right"
+	WatermarkTiled       = "tiled"
+
+	WatermarkForeground = "foreground"
+	WatermarkBackground = "background"
+)
+
+// watermarkMargin keeps corner marks off the page edge (0.5in)
+const watermarkMargin = 36.0
+
+// watermarkMark is a mark drawn with its bottom-left corner at the origin
+type watermarkMark struct {
+	width, height float64
+	content       string
+}
+
+// watermarkStyle holds the resolved watermark settings shared by all pages
+type watermarkStyle struct {
+	wm       *models.Watermark
+	position string
+	fontSize float64
+	rotation float64
+	opacity  string // ExtGState resource name
+	font     string // Font resource name (text marks)
+	image    *imageInfo
+	imageRes string // XObject resource name (image marks)
+}
+
+// buildWatermarkStamp renders one stamp page per box
+func buildWatermarkStamp(wm *models.Watermark, boxes []pageBox) ([]byte, error) {
+	s := newStampWriter()
+
+	style := &watermarkStyle{
+		wm:       wm,
+		position: wm.Position,
+		fontSize: wm.FontSize,
+		rotation: wm.Rotation,
+	}
+	if style.position == "" {
+		style.position = WatermarkCenter
+	}
+	if style.fontSize <= 0 {
+		style.fontSize = 48
+	}
+	if style.rotation == 0 && (style.position == WatermarkCenter || style.position == WatermarkTiled) {
+		// Diagonal by default; corner marks stay level
+		style.rotation = 45
+	}
+
+	opacity := wm.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 0.3
+	}
+	style.opacity = s.opacity(opacity)
+
+	if wm.Image != "" {
+		data, err := decodeImageInput(wm.Image)
+		if err != nil {
+			return nil, fmt.Errorf("watermark image: %w", err)
+		}
+		style.image = inspectImage(data)
+		switch style.image.Format {
+		case "jpeg", "png", "gif":
+		default:
+			return nil, fmt.Errorf("watermark image must be PNG, JPEG or GIF")
+		}
+		if style.imageRes, err = s.image("Wm", style.image); err != nil {
+			return nil, err
+		}
+	} else {
+		style.font = s.font(fontHelveticaBold)
+	}
+
+	for _, box := range boxes {
+		s.addPage(box, style.pageContent(box))
+	}
+	return s.finish(), nil
+}
+
+// mark builds the text or image mark for a page
+func (st *watermarkStyle) mark(box pageBox) watermarkMark {
+	if st.image != nil {
+		scale := st.wm.ImageScale
+		if scale <= 0 || scale > 1 {
+			scale = 0.3
+		}
+		w := box.Width * scale
+		h := w * float64(st.image.Height) / float64(st.image.Width)
+
+		content := fmt.Sprintf("%s 0 0 %s 0 0 cm\n", pdfNumber(w), pdfNumber(h))
+		if m := exifMatrix(st.image.Orientation); m != "" {
+			content += m + " cm\n"
+		}
+		content += fmt.Sprintf("/%s Do\n", st.imageRes)
+		return watermarkMark{width: w, height: h, content: content}
+	}
+
+	rgb := parseColor(st.wm.Color, namedColors["gray"])
+	content := fmt.Sprintf("%s %s %s rg\nBT /%s %s Tf 0 0 Td %s Tj ET\n",
+		pdfNumber(rgb[0]), pdfNumber(rgb[1]), pdfNumber(rgb[2]),
+		st.font, pdfNumber(st.fontSize), pdfTextString(st.wm.Text))
+	return watermarkMark{
+		width:   textWidth(fontHelveticaBold, st.wm.Text, st.fontSize),
+		height:  st.fontSize * fontCapHeight / 1000,
+		content: content,
+	}
+}
+
+// pageContent places the mark once, in a corner, or tiled across the page
+func (st *watermarkStyle) pageContent(box pageBox) []byte {
+	mark := st.mark(box)
+
+	rad := st.rotation * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	// Size of the rotated mark's bounding box
+	boundW := math.Abs(mark.width*cos) + math.Abs(mark.height*sin)
+	boundH := math.Abs(mark.width*sin) + math.Abs(mark.height*cos)
+
+	var centers [][2]float64
+	switch st.position {
+	case WatermarkTopLeft:
+		centers = append(centers, [2]float64{watermarkMargin + boundW/2, box.Height - watermarkMargin - boundH/2})
+	case WatermarkTopRight:
+		centers = append(centers, [2]float64{box.Width - watermarkMargin - boundW/2, box.Height - watermarkMargin - boundH/2})
+	case WatermarkBottomLeft:
+		centers = append(centers, [2]float64{watermarkMargin + boundW/2, watermarkMargin + boundH/2})
+	case WatermarkBottomRight:
+		centers = append(centers, [2]float64{box.Width - watermarkMargin - boundW/2, watermarkMargin + boundH/2})
+	case WatermarkTiled:
+		gap := math.Max(mark.height, 24)
+		stepX, stepY := boundW+gap, boundH+gap
+		for row, y := 0, stepY/2; y-boundH/2 < box.Height; row, y = row+1, y+stepY {
+			// Offset alternate rows for a brick pattern
+			x := stepX / 2
+			if row%2 == 1 {
+				x = 0
+			}
+			for ; x-boundW/2 < box.Width; x += stepX {
+				centers = append(centers, [2]float64{x, y})
+			}
+		}
+	default:
+		centers = append(centers, [2]float64{box.Width / 2, box.Height / 2})
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "q /%s gs\n", st.opacity)
+	for _, c := range centers {
+		// Move to the center, rotate, then draw the mark centered on the origin
+		fmt.Fprintf(&b, "q 1 0 0 1 %s %s cm %s %s %s %s 0 0 cm 1 0 0 1 %s %s cm\n",
+			pdfNumber(c[0]), pdfNumber(c[1]),
+			pdfNumber(cos), pdfNumber(sin), pdfNumber(-sin), pdfNumber(cos),
+			pdfNumber(-mark.width/2), pdfNumber(-mark.height/2))
+		b.WriteString(mark.content)
+		b.WriteString("Q\n")
+	}
+	b.WriteString("Q\n")
+	return b.Bytes()
+}
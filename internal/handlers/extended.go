@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -87,6 +88,65 @@ func (h *ExtendedHandler) Template(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Build the e-invoice XML; it's attached while converting to PDF/A-3
+	var facturX *templates.FacturX
+	if req.EInvoice != nil {
+		if templates.TemplateType(req.Template) != templates.TemplateInvoice {
+			h.errorResponse(w, http.StatusBadRequest, "e_invoice is only supported for the invoice template", requestID)
+			return
+		}
+		if h.processor == nil {
+			h.errorResponse(w, http.StatusServiceUnavailable, "PDF processor unavailable", requestID)
+			return
+		}
+		facturX, err = templates.BuildFacturX(req.Data, req.EInvoice.Profile)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, err.Error(), requestID)
+			return
+		}
+
+		if req.Options == nil {
+			req.Options = &models.PDFOptions{}
+		}
+		pdfa := models.PDFAOptions{Level: converters.PDFA3b}
+		if req.Options.PDFA != nil {
+			if req.Options.PDFA.Level != "" && req.Options.PDFA.Level != converters.PDFA3b {
+				h.errorResponse(w, http.StatusBadRequest, "e-invoices require PDF/A level 3b", requestID)
+				return
+			}
+			pdfa.Attachments = append(pdfa.Attachments, req.Options.PDFA.Attachments...)
+		}
+		pdfa.Attachments = append(pdfa.Attachments, models.Attachment{
+			Name:         templates.FacturXFileName,
+			Data:         base64.StdEncoding.EncodeToString(facturX.XML),
+			MIMEType:     "text/xml",
+			Description:  "Factur-X invoice",
+			Relationship: facturX.Relationship,
+		})
+		req.Options.PDFA = &pdfa
+	}
+
+	// Signing happens last, after the e-invoice metadata. Contracts are
+	// always signed before they leave the service.
+	var sign *models.SignatureOptions
+	if req.Options != nil {
+		sign, req.Options.Sign = req.Options.Sign, nil
+	}
+	if templates.TemplateType(req.Template) == templates.TemplateContract && sign == nil {
+		sign = &models.SignatureOptions{Reason: "Contract issued"}
+	}
+	if sign != nil {
+		if h.processor == nil || !h.processor.CanSign() {
+			h.errorResponse(w, http.StatusServiceUnavailable, "Document signing is not configured", requestID)
+			return
+		}
+		if req.Options != nil && req.Options.Security != nil &&
+			(req.Options.Security.UserPassword != "" || req.Options.Security.OwnerPassword != "") {
+			h.errorResponse(w, http.StatusBadRequest, "Signed documents cannot be encrypted", requestID)
+			return
+		}
+	}
+
 	// Convert to PDF
 	pdfData, err := h.converter.ConvertHTML(r.Context(), html, req.Options)
 	if err != nil {
@@ -102,7 +162,23 @@ func (h *ExtendedHandler) Template(w http.ResponseWriter, r *http.Request) {
 	if req.Options != nil && h.processor != nil {
 		pdfData, err = h.processor.Process(pdfData, req.Options)
 		if err != nil {
-			h.errorResponse(w, http.StatusInternalServerError, "Post-processing failed: "+err.Error(), requestID)
+			h.errorResponse(w, processingStatus(err), "Post-processing failed: "+err.Error(), requestID)
+			return
+		}
+	}
+
+	if facturX != nil {
+		pdfData, err = h.processor.AddFacturXMetadata(pdfData, templates.FacturXFileName, facturX.Profile)
+		if err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "E-invoice metadata failed: "+err.Error(), requestID)
+			return
+		}
+	}
+
+	if sign != nil {
+		pdfData, err = h.processor.Sign(pdfData, sign)
+		if err != nil {
+			h.errorResponse(w, processingStatus(err), "Signing failed: "+err.Error(), requestID)
 			return
 		}
 	}
@@ -146,6 +222,58 @@ func (h *ExtendedHandler) Manipulate(w http.ResponseWriter, r *http.Request) {
 		Success:   true,
 	}
 
+	// Open encrypted inputs once so every operation works on them; the
+	// documents in pdfs take the same password
+	decrypt := func(data []byte, name string) ([]byte, bool) {
+		if req.Password == "" || len(data) == 0 {
+			return data, true
+		}
+		decrypted, err := h.manipulator.Decrypt(ctx, data, req.Password)
+		if errors.Is(err, converters.ErrInvalidPassword) {
+			h.errorResponse(w, http.StatusForbidden, "Invalid password for "+name, requestID)
+			return nil, false
+		}
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "Failed to decrypt "+name+": "+err.Error(), requestID)
+			return nil, false
+		}
+		return decrypted, true
+	}
+	original := pdfData
+	var ok bool
+	if pdfData, ok = decrypt(pdfData, "pdf"); !ok {
+		return
+	}
+	others := make([][]byte, len(req.PDFs))
+	for i, doc := range req.PDFs {
+		name := fmt.Sprintf("pdfs[%d]", i)
+		data, err := base64.StdEncoding.DecodeString(doc)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "Invalid Base64 PDF data in "+name, requestID)
+			return
+		}
+		if others[i], ok = decrypt(data, name); !ok {
+			return
+		}
+	}
+
+	// Lifting or changing restrictions needs the owner password, not just
+	// one that opens the file
+	if req.Operation == "decrypt" || req.Operation == "change_permissions" {
+		err := h.manipulator.RequireOwnerPassword(ctx, original, req.Password)
+		switch {
+		case errors.Is(err, converters.ErrInvalidPassword):
+			h.errorResponse(w, http.StatusForbidden, "Invalid password", requestID)
+			return
+		case errors.Is(err, converters.ErrOwnerPasswordRequired):
+			h.errorResponse(w, http.StatusForbidden, "The owner password is required for "+req.Operation, requestID)
+			return
+		case err != nil:
+			h.errorResponse(w, http.StatusBadRequest, "Failed to read encryption: "+err.Error(), requestID)
+			return
+		}
+	}
+
 	switch req.Operation {
 	case "split":
 		splitReq := &converters.SplitRequest{
@@ -223,6 +351,10 @@ func (h *ExtendedHandler) Manipulate(w http.ResponseWriter, r *http.Request) {
 
 	case "info":
 		info, err := h.manipulator.GetInfo(ctx, pdfData)
+		if err == nil {
+			info.Encryption, err = h.manipulator.EncryptionInfo(ctx, original, req.Password)
+			info.Encrypted = info.Encryption != nil
+		}
 		if err != nil {
 			result.Success = false
 			result.Message = err.Error()
@@ -282,6 +414,328 @@ func (h *ExtendedHandler) Manipulate(w http.ResponseWriter, r *http.Request) {
 			result.Message = fmt.Sprintf("Converted to %d images", len(images))
 		}
 
+	case "set_metadata":
+		if req.Options == nil || req.Options.Metadata == nil {
+			h.errorResponse(w, http.StatusBadRequest, "metadata parameter is required for set_metadata", requestID)
+			return
+		}
+		updated, err := h.manipulator.SetMetadata(ctx, pdfData, req.Options.Metadata)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(updated)
+			result.Message = "Metadata updated successfully"
+		}
+
+	case "number_pages":
+		var docs [][]byte
+		if len(pdfData) > 0 {
+			docs = append(docs, pdfData)
+		}
+		docs = append(docs, others...)
+		if len(docs) == 0 {
+			h.errorResponse(w, http.StatusBadRequest, "pdf or pdfs is required for number_pages", requestID)
+			return
+		}
+
+		var numbering *models.PageNumbering
+		if req.Options != nil {
+			numbering = req.Options.Numbering
+		}
+		numbered, next, err := h.manipulator.NumberPages(ctx, docs, numbering)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			if len(numbered) == 1 {
+				result.PDF = base64.StdEncoding.EncodeToString(numbered[0])
+			} else {
+				for _, doc := range numbered {
+					result.Files = append(result.Files, base64.StdEncoding.EncodeToString(doc))
+				}
+				result.Count = len(numbered)
+			}
+			result.NextNumber = next
+			result.Message = fmt.Sprintf("Numbered %d document(s)", len(numbered))
+		}
+
+	case "to_pdfa":
+		if h.processor == nil {
+			h.errorResponse(w, http.StatusServiceUnavailable, "PDF processor unavailable", requestID)
+			return
+		}
+		var pdfa *models.PDFAOptions
+		if req.Options != nil {
+			pdfa = req.Options.PDFA
+		}
+		converted, err := h.processor.ConvertToPDFA(pdfData, pdfa)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(converted)
+			result.Message = "Converted to PDF/A"
+		}
+
+	case "to_pdfx":
+		if h.processor == nil {
+			h.errorResponse(w, http.StatusServiceUnavailable, "PDF processor unavailable", requestID)
+			return
+		}
+		var profile *models.PrintProfile
+		if req.Options != nil {
+			profile = req.Options.PrintProfile
+		}
+		converted, err := h.processor.ConvertToPDFX(pdfData, profile)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(converted)
+			result.Message = "Converted to PDF/X"
+		}
+
+	case "sign":
+		if h.processor == nil {
+			h.errorResponse(w, http.StatusServiceUnavailable, "PDF processor unavailable", requestID)
+			return
+		}
+		if !h.processor.CanSign() {
+			h.errorResponse(w, http.StatusServiceUnavailable, "Document signing is not configured", requestID)
+			return
+		}
+		var sign *models.SignatureOptions
+		if req.Options != nil {
+			sign = req.Options.Sign
+		}
+		signed, err := h.processor.Sign(pdfData, sign)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(signed)
+			result.Message = "Signed"
+		}
+
+	case "inspect_signatures":
+		if h.processor == nil {
+			h.errorResponse(w, http.StatusServiceUnavailable, "PDF processor unavailable", requestID)
+			return
+		}
+		sigs, err := h.processor.InspectSignatures(pdfData)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			valid := 0
+			for _, sig := range sigs {
+				if sig.DigestValid && sig.Trusted {
+					valid++
+				}
+			}
+			result.Signatures = sigs
+			result.Count = len(sigs)
+			result.Message = fmt.Sprintf("Found %d signature field(s), %d valid and trusted", len(sigs), valid)
+		}
+
+	case "form_fields":
+		fields, err := h.manipulator.FormFields(ctx, pdfData)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.FormFields = fields
+			result.Count = len(fields)
+			result.Message = fmt.Sprintf("Found %d form field(s)", len(fields))
+		}
+
+	case "fill_form":
+		if req.Options == nil || (len(req.Options.Fields) == 0 && !req.Options.Flatten) {
+			h.errorResponse(w, http.StatusBadRequest, "fields or flatten is required for fill_form", requestID)
+			return
+		}
+		filled, err := h.manipulator.FillForm(ctx, pdfData, req.Options.Fields, req.Options.Flatten)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(filled)
+			result.Message = fmt.Sprintf("Filled %d field(s)", len(req.Options.Fields))
+		}
+
+	case "extract_text":
+		pageRange, mode := "", ""
+		if req.Options != nil {
+			pageRange, mode = req.Options.Pages, req.Options.TextMode
+		}
+		pages, err := h.manipulator.ExtractText(ctx, pdfData, pageRange, mode)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.Text = pages
+			result.Count = len(pages)
+			result.Message = fmt.Sprintf("Extracted text from %d pages", len(pages))
+		}
+
+	case "ocr":
+		var pageRange string
+		var ocr *models.OCROptions
+		if req.Options != nil {
+			pageRange, ocr = req.Options.Pages, req.Options.OCR
+		}
+		searchable, pages, err := h.manipulator.OCR(ctx, pdfData, pageRange, ocr)
+		if errors.Is(err, converters.ErrNoOCR) {
+			h.errorResponse(w, http.StatusServiceUnavailable, "OCR is not available", requestID)
+			return
+		}
+		if errors.Is(err, converters.ErrInvalidOptions) {
+			h.errorResponse(w, http.StatusBadRequest, err.Error(), requestID)
+			return
+		}
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(searchable)
+			result.OCR = pages
+			result.Count = len(pages)
+			result.Message = fmt.Sprintf("Recognized text on %d pages", len(pages))
+		}
+
+	case "redact":
+		if req.Options == nil || req.Options.Redact == nil {
+			h.errorResponse(w, http.StatusBadRequest, "redact parameter is required for redact", requestID)
+			return
+		}
+		redacted, pages, err := h.manipulator.Redact(ctx, pdfData, req.Options.Pages, req.Options.Redact)
+		if errors.Is(err, converters.ErrNoRedaction) {
+			h.errorResponse(w, http.StatusServiceUnavailable, "Redaction is not available", requestID)
+			return
+		}
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			total := 0
+			for _, page := range pages {
+				total += len(page.Redactions)
+			}
+			result.PDF = base64.StdEncoding.EncodeToString(redacted)
+			result.Redactions = pages
+			result.Count = total
+			result.Message = fmt.Sprintf("Redacted %d region(s) on %d page(s)", total, len(pages))
+		}
+
+	case "compare":
+		if len(req.PDFs) != 1 {
+			h.errorResponse(w, http.StatusBadRequest, "pdfs must hold the one PDF to compare with", requestID)
+			return
+		}
+		var pageRange string
+		var compare *models.CompareOptions
+		if req.Options != nil {
+			pageRange, compare = req.Options.Pages, req.Options.Compare
+		}
+		comparison, err := h.manipulator.Compare(ctx, pdfData, others[0], pageRange, compare)
+		if errors.Is(err, converters.ErrInvalidOptions) {
+			h.errorResponse(w, http.StatusBadRequest, err.Error(), requestID)
+			return
+		}
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.Comparison = comparison
+			result.Count = len(comparison.Pages)
+			if comparison.Passed {
+				result.Message = "Documents match"
+			} else {
+				result.Message = fmt.Sprintf("%d of %d pages differ", comparison.Failed, len(comparison.Pages))
+			}
+		}
+
+	case "overlay", "underlay":
+		if len(req.PDFs) != 1 {
+			h.errorResponse(w, http.StatusBadRequest, "pdfs must hold the one PDF to "+req.Operation, requestID)
+			return
+		}
+		var pageRange string
+		var opts *models.LayerOptions
+		if req.Options != nil {
+			pageRange, opts = req.Options.Pages, req.Options.Layer
+		}
+		apply := h.manipulator.Overlay
+		if req.Operation == "underlay" {
+			apply = h.manipulator.Underlay
+		}
+		layered, err := apply(ctx, pdfData, others[0], pageRange, opts)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(layered)
+			result.Message = "Applied " + req.Operation
+		}
+
+	case "nup", "booklet", "resize", "crop":
+		var pageRange string
+		var opts *models.LayoutOptions
+		if req.Options != nil {
+			pageRange, opts = req.Options.Pages, req.Options.Layout
+		}
+		var laidOut []byte
+		switch req.Operation {
+		case "nup":
+			laidOut, err = h.manipulator.NUp(ctx, pdfData, pageRange, opts)
+		case "booklet":
+			laidOut, err = h.manipulator.Booklet(ctx, pdfData, pageRange, opts)
+		case "resize":
+			laidOut, err = h.manipulator.Resize(ctx, pdfData, pageRange, opts)
+		default:
+			laidOut, err = h.manipulator.Crop(ctx, pdfData, pageRange, opts)
+		}
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(laidOut)
+			result.Message = "Applied " + req.Operation
+		}
+
+	case "decrypt":
+		// With a password the input is already decrypted; without one the
+		// owner password is empty
+		if req.Password == "" {
+			pdfData, err = h.manipulator.Decrypt(ctx, pdfData, "")
+		}
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(pdfData)
+			result.Message = "PDF decrypted"
+		}
+
+	case "change_permissions":
+		if req.Options == nil || req.Options.Security == nil {
+			h.errorResponse(w, http.StatusBadRequest, "security is required for change_permissions", requestID)
+			return
+		}
+		if req.Options.Security.OwnerPassword == "" {
+			h.errorResponse(w, http.StatusBadRequest, "owner_password is required for change_permissions", requestID)
+			return
+		}
+		changed, err := h.manipulator.ChangePermissions(ctx, pdfData, "", req.Options.Security)
+		if err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		} else {
+			result.PDF = base64.StdEncoding.EncodeToString(changed)
+			result.Message = "Permissions changed"
+		}
+
 	default:
 		h.errorResponse(w, http.StatusBadRequest, "Unknown operation: "+req.Operation, requestID)
 		return
@@ -331,9 +785,10 @@ func (h *ExtendedHandler) processAsync(requestID string, req *models.AsyncReques
 	startTime := time.Now()
 	convType := string(req.Request.Type)
 
-	// Perform conversion
+	// Perform conversion; Markdown front matter adds to a copy of the options
 	var pdfData []byte
 	var err error
+	opts := req.Request.Options
 
 	switch req.Request.Type {
 	case models.ConvertHTML:
@@ -352,7 +807,7 @@ func (h *ExtendedHandler) processAsync(requestID string, req *models.AsyncReques
 	case models.ConvertURL:
 		pdfData, err = h.converter.ConvertURL(ctx, req.Request.URL, req.Request.Options)
 	case models.ConvertMarkdown:
-		pdfData, err = h.converter.ConvertMarkdown(ctx, req.Request.Markdown, req.Request.Options)
+		pdfData, opts, err = h.converter.ConvertMarkdown(ctx, req.Request.Markdown, opts)
 	case models.ConvertImage:
 		pdfData, err = h.converter.ConvertImage(ctx, req.Request.Image, req.Request.Options)
 	case models.ConvertImages:
@@ -364,8 +819,8 @@ func (h *ExtendedHandler) processAsync(requestID string, req *models.AsyncReques
 	duration := time.Since(startTime)
 
 	// Apply post-processing
-	if err == nil && req.Request.Options != nil && h.processor != nil {
-		pdfData, err = h.processor.Process(pdfData, req.Request.Options)
+	if err == nil && opts != nil && h.processor != nil {
+		pdfData, err = h.processor.Process(pdfData, opts)
 	}
 
 	// Upload to storage if configured
@@ -443,7 +898,7 @@ func (h *ExtendedHandler) Batch(w http.ResponseWriter, r *http.Request) {
 		case models.ConvertURL:
 			pdfData, err = h.converter.ConvertURL(ctx, convReq.URL, convReq.Options)
 		case models.ConvertMarkdown:
-			pdfData, err = h.converter.ConvertMarkdown(ctx, convReq.Markdown, convReq.Options)
+			pdfData, convReq.Options, err = h.converter.ConvertMarkdown(ctx, convReq.Markdown, convReq.Options)
 		case models.ConvertImage:
 			pdfData, err = h.converter.ConvertImage(ctx, convReq.Image, convReq.Options)
 		case models.ConvertImages:
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -35,12 +36,20 @@ func NewHandler(c *converters.ChromeConverter, p *converters.PDFProcessor, l *sl
 }
 
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	// Simple health check
+	workers := h.converter.GetWorkerStatus()
+
+	// Report degraded while crashed browsers are being respawned
+	status, chrome := "healthy", "running"
+	if workers.Pool != nil && workers.Pool.Browsers < workers.Max {
+		status, chrome = "degraded", "recovering"
+	}
+
 	response := models.HealthResponse{
-		Status:  "healthy",
+		Status:  status,
 		Version: h.version,
 		Uptime:  time.Since(h.startTime).String(),
-		Chrome:  "running",
+		Workers: workers,
+		Chrome:  chrome,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -81,7 +90,8 @@ func (h *Handler) Convert(w http.ResponseWriter, r *http.Request) {
 	case models.ConvertURL:
 		pdfData, err = h.converter.ConvertURL(ctx, req.URL, req.Options)
 	case models.ConvertMarkdown:
-		pdfData, err = h.converter.ConvertMarkdown(ctx, req.Markdown, req.Options)
+		// Post-process with the front matter metadata merged in
+		pdfData, req.Options, err = h.converter.ConvertMarkdown(ctx, req.Markdown, req.Options)
 	case models.ConvertImage:
 		pdfData, err = h.converter.ConvertImage(ctx, req.Image, req.Options)
 	case models.ConvertImages:
@@ -107,7 +117,7 @@ func (h *Handler) Convert(w http.ResponseWriter, r *http.Request) {
 		pdfData, err = h.processor.Process(pdfData, req.Options)
 		if err != nil {
 			h.logger.Error("Processing failed", "request_id", requestID, "error", err)
-			h.errorResponse(w, http.StatusInternalServerError, "Processing failed: "+err.Error(), requestID)
+			h.errorResponse(w, processingStatus(err), "Processing failed: "+err.Error(), requestID)
 			return
 		}
 	}
@@ -141,6 +151,22 @@ func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// processingStatus maps a post-processing error to an HTTP status:
+// conflicting options and output that can't meet the requested PDF/A level
+// or PDF/X standard are the request's fault, a missing signing certificate
+// or OCR engine the server's configuration
+func processingStatus(err error) int {
+	switch {
+	case errors.Is(err, converters.ErrInvalidOptions):
+		return http.StatusBadRequest
+	case errors.Is(err, converters.ErrPDFAConformance), errors.Is(err, converters.ErrPDFXConformance):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, converters.ErrNoSigner), errors.Is(err, converters.ErrNoOCR):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
 // errorResponse helper required by ExtendedHandler
 func (h *Handler) errorResponse(w http.ResponseWriter, status int, message, requestID string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // ConversionType defines the type of conversion
 type ConversionType string
 
@@ -56,6 +58,20 @@ func (p PageSize) GetDimensions() PageDimensions {
 	}
 }
 
+// PageDimensions resolves the page size, custom dimensions and orientation
+// into the final paper size in inches
+func (o *PDFOptions) PageDimensions() PageDimensions {
+	dims := o.PageSize.GetDimensions()
+	if o.PageSize == PageCustom && o.CustomDimensions != nil &&
+		o.CustomDimensions.Width > 0 && o.CustomDimensions.Height > 0 {
+		dims = *o.CustomDimensions
+	}
+	if o.Orientation == Landscape {
+		dims.Width, dims.Height = dims.Height, dims.Width
+	}
+	return dims
+}
+
 // Margins for PDF pages (in inches)
 type Margins struct {
 	Top    float64 `json:"top"`
@@ -80,12 +96,21 @@ type PDFSecurity struct {
 }
 
 // PDFMetadata holds document metadata
+//
+// Values are written to both the Info dictionary and the XMP packet.
 type PDFMetadata struct {
 	Title    string `json:"title,omitempty"`
 	Author   string `json:"author,omitempty"`
 	Subject  string `json:"subject,omitempty"`
 	Keywords string `json:"keywords,omitempty"`
 	Creator  string `json:"creator,omitempty"`
+	Producer string `json:"producer,omitempty"`
+	Language string `json:"language,omitempty"` // BCP 47 tag such as en-US, stored as the catalog /Lang
+
+	CreationDate     *time.Time `json:"creation_date,omitempty"`     // RFC 3339; existing value kept when empty
+	ModificationDate *time.Time `json:"modification_date,omitempty"` // RFC 3339; defaults to now
+
+	Custom map[string]string `json:"custom,omitempty"` // Extra Info dictionary entries
 }
 
 // Watermark configuration
@@ -95,9 +120,18 @@ type Watermark struct {
 	Opacity  float64 `json:"opacity,omitempty"` // 0.0 to 1.0
 	Rotation float64 `json:"rotation,omitempty"`
 	Color    string  `json:"color,omitempty"` // Hex color
+
+	Image      string  `json:"image,omitempty"`       // Base64 PNG/JPEG logo, used instead of text
+	ImageScale float64 `json:"image_scale,omitempty"` // Image width as a fraction of the page width, default 0.3
+	Position   string  `json:"position,omitempty"`    // center (default), top_left, top_right, bottom_left, bottom_right, tiled
+	Pages      string  `json:"pages,omitempty"`       // Page selection, e.g. "1-3,5", "odd"; default all
+	Layer      string  `json:"layer,omitempty"`       // foreground (default) or background
 }
 
 // HeaderFooter configuration
+//
+// Text fields support the placeholders {page}, {total}, {date}, {title} and {url}.
+// HeaderHTML/FooterHTML take a raw Chrome template and override the text fields.
 type HeaderFooter struct {
 	HeaderLeft   string  `json:"header_left,omitempty"`
 	HeaderCenter string  `json:"header_center,omitempty"`
@@ -106,21 +140,135 @@ type HeaderFooter struct {
 	FooterCenter string  `json:"footer_center,omitempty"`
 	FooterRight  string  `json:"footer_right,omitempty"`
 	FontSize     float64 `json:"font_size,omitempty"`
+	HeaderHTML   string  `json:"header_html,omitempty"` // Raw HTML header template
+	FooterHTML   string  `json:"footer_html,omitempty"` // Raw HTML footer template
+}
+
+// WaitStrategy defines how to decide that a page is ready to print
+type WaitStrategy string
+
+const (
+	WaitSelector    WaitStrategy = "selector"     // CSS selector is present
+	WaitNetworkIdle WaitStrategy = "network_idle" // No requests for IdleTime ms
+	WaitExpression  WaitStrategy = "expression"   // JS expression becomes truthy
+	WaitFonts       WaitStrategy = "fonts"        // document.fonts.ready resolves
+	WaitDelay       WaitStrategy = "delay"        // Fixed delay
+)
+
+// WaitFor configures page readiness before printing
+type WaitFor struct {
+	Strategy   WaitStrategy `json:"strategy"`
+	Selector   string       `json:"selector,omitempty"`   // For selector
+	Expression string       `json:"expression,omitempty"` // For expression, e.g. "window.pdfReady === true"
+	IdleTime   int          `json:"idle_ms,omitempty"`    // For network_idle, default 500
+	Delay      int          `json:"delay_ms,omitempty"`   // For delay
+	Timeout    int          `json:"timeout_ms,omitempty"` // Default 30000
+}
+
+// MarkdownOptions controls Markdown rendering
+type MarkdownOptions struct {
+	Theme          string `json:"theme,omitempty"`           // github (default), academic, minimal
+	CustomCSS      string `json:"custom_css,omitempty"`      // Appended after the theme
+	HighlightStyle string `json:"highlight_style,omitempty"` // Chroma style name, default github
+}
+
+// OutlineOptions builds navigation from h1-h6 headings
+type OutlineOptions struct {
+	Bookmarks bool   `json:"bookmarks,omitempty"` // Nested PDF bookmark outline
+	TOC       bool   `json:"toc,omitempty"`       // Prepend a table of contents page
+	TOCTitle  string `json:"toc_title,omitempty"` // Default "Table of Contents"
+	MaxLevel  int    `json:"max_level,omitempty"` // Deepest heading level, default 3
+}
+
+// ImageOptions controls how image conversions lay out their images
+type ImageOptions struct {
+	Layout       string   `json:"layout,omitempty"`         // page (one per page, default) or grid
+	PerPage      int      `json:"per_page,omitempty"`       // Images per page in grid layout, default 4
+	Columns      int      `json:"columns,omitempty"`        // Grid columns, default ceil(sqrt(per_page))
+	Fit          string   `json:"fit,omitempty"`            // contain (default), cover, stretch, actual
+	PageFitImage bool     `json:"page_fit_image,omitempty"` // Size each page to its image (page layout only)
+	Captions     []string `json:"captions,omitempty"`       // Caption per image, in order
+}
+
+// PDFAOptions converts the output to PDF/A for archiving
+type PDFAOptions struct {
+	Level       string       `json:"level,omitempty"`       // 1b, 2b (default), 2u, 3b
+	Attachments []Attachment `json:"attachments,omitempty"` // Embedded files, PDF/A-3 only
+}
+
+// PrintProfile prepares the output for commercial printing as PDF/X, with
+// colors converted to CMYK for an output profile
+type PrintProfile struct {
+	Standard        string  `json:"standard,omitempty"`         // X-1a, X-4 (default)
+	ICCProfile      string  `json:"icc_profile,omitempty"`      // Base64 CMYK output profile, default the server's
+	OutputCondition string  `json:"output_condition,omitempty"` // Registered condition such as FOGRA39, default Custom
+	Bleed           float64 `json:"bleed,omitempty"`            // Points added beyond the trim on each edge
+	CropMarks       bool    `json:"crop_marks,omitempty"`       // Trim marks outside the bleed
+}
+
+// OCROptions adds an invisible, searchable text layer recognized from the
+// page images
+type OCROptions struct {
+	Languages []string `json:"languages,omitempty"` // Tesseract language codes, default ["eng"]
+	DPI       int      `json:"dpi,omitempty"`       // Rendering resolution, default 300, at most 600
+}
+
+// OCRPage reports how well the text of one page was recognized
+type OCRPage struct {
+	Page       int     `json:"page"`
+	Words      int     `json:"words"`
+	Confidence float64 `json:"confidence"` // Mean word confidence, 0-100
+}
+
+// Attachment is a file embedded in a PDF/A-3 document
+type Attachment struct {
+	Name         string `json:"name"`
+	Data         string `json:"data"`                // Base64 encoded
+	MIMEType     string `json:"mime_type,omitempty"` // Default application/octet-stream
+	Description  string `json:"description,omitempty"`
+	Relationship string `json:"relationship,omitempty"` // Data, Source, Alternative, Supplement, Unspecified (default)
+}
+
+// SignatureOptions applies a PAdES digital signature with the server's
+// configured certificate
+type SignatureOptions struct {
+	Reason       string               `json:"reason,omitempty"`
+	Location     string               `json:"location,omitempty"`
+	ContactInfo  string               `json:"contact_info,omitempty"`
+	TimestampURL string               `json:"timestamp_url,omitempty"` // RFC 3161 TSA, overrides the configured default
+	Appearance   *SignatureAppearance `json:"appearance,omitempty"`    // Visible signature; invisible when nil
+}
+
+// SignatureAppearance places a visible signature box on a page
+type SignatureAppearance struct {
+	Page     int        `json:"page,omitempty"`      // 1-based, default 1; negative counts from the end
+	Rect     [4]float64 `json:"rect"`                // x, y, width, height in points from the bottom-left corner
+	Text     string     `json:"text,omitempty"`      // Default: signer name, date, reason and location
+	Image    string     `json:"image,omitempty"`     // Base64 PNG/JPEG drawn on the left of the box
+	FontSize float64    `json:"font_size,omitempty"` // Default 8
 }
 
 // PDFOptions contains all PDF generation options
 type PDFOptions struct {
-	PageSize         PageSize        `json:"page_size,omitempty"`
-	CustomDimensions *PageDimensions `json:"custom_dimensions,omitempty"`
-	Orientation      Orientation     `json:"orientation,omitempty"`
-	Margins          *Margins        `json:"margins,omitempty"`
-	Security         *PDFSecurity    `json:"security,omitempty"`
-	Metadata         *PDFMetadata    `json:"metadata,omitempty"`
-	Watermark        *Watermark      `json:"watermark,omitempty"`
-	HeaderFooter     *HeaderFooter   `json:"header_footer,omitempty"`
-	PrintBackground  bool            `json:"print_background"`
-	Scale            float64         `json:"scale,omitempty"` // 0.1 to 2.0
-	Grayscale        bool            `json:"grayscale,omitempty"`
+	PageSize         PageSize          `json:"page_size,omitempty"`
+	CustomDimensions *PageDimensions   `json:"custom_dimensions,omitempty"`
+	Orientation      Orientation       `json:"orientation,omitempty"`
+	Margins          *Margins          `json:"margins,omitempty"`
+	Security         *PDFSecurity      `json:"security,omitempty"`
+	Metadata         *PDFMetadata      `json:"metadata,omitempty"`
+	Watermark        *Watermark        `json:"watermark,omitempty"`
+	HeaderFooter     *HeaderFooter     `json:"header_footer,omitempty"`
+	PrintBackground  *bool             `json:"print_background,omitempty"` // Defaults to true
+	Scale            float64           `json:"scale,omitempty"`            // 0.1 to 2.0
+	Grayscale        bool              `json:"grayscale,omitempty"`
+	WaitFor          *WaitFor          `json:"wait_for,omitempty"`
+	Markdown         *MarkdownOptions  `json:"markdown,omitempty"`
+	Outline          *OutlineOptions   `json:"outline,omitempty"`
+	Images           *ImageOptions     `json:"images,omitempty"`
+	PDFA             *PDFAOptions      `json:"pdfa,omitempty"`
+	Sign             *SignatureOptions `json:"sign,omitempty"`
+	OCR              *OCROptions       `json:"ocr,omitempty"`
+	PrintProfile     *PrintProfile     `json:"print_profile,omitempty"`
 }
 
 // DefaultOptions returns sensible defaults
@@ -129,11 +277,24 @@ func DefaultOptions() PDFOptions {
 		PageSize:        PageA4,
 		Orientation:     Portrait,
 		Margins:         &Margins{Top: 0.4, Bottom: 0.4, Left: 0.4, Right: 0.4},
-		PrintBackground: true,
+		PrintBackground: Bool(true),
 		Scale:           1.0,
 	}
 }
 
+// ShouldPrintBackground reports whether background graphics are printed (default true)
+func (o *PDFOptions) ShouldPrintBackground() bool {
+	if o == nil || o.PrintBackground == nil {
+		return true
+	}
+	return *o.PrintBackground
+}
+
+// Bool returns a pointer to the given bool, for optional fields
+func Bool(v bool) *bool {
+	return &v
+}
+
 // ConversionRequest is the main request structure
 type ConversionRequest struct {
 	Type ConversionType `json:"type"`
@@ -179,9 +340,26 @@ type HealthResponse struct {
 
 // WorkerStatus shows worker pool status
 type WorkerStatus struct {
-	Max       int `json:"max"`
-	Available int `json:"available"`
-	InUse     int `json:"in_use"`
+	Max       int         `json:"max"`
+	Available int         `json:"available"`
+	InUse     int         `json:"in_use"`
+	Pool      *PoolStatus `json:"pool,omitempty"`
+}
+
+// PoolStatus shows the state of the warm browser pool
+type PoolStatus struct {
+	Browsers int   `json:"browsers"` // Live browser processes
+	Renders  int64 `json:"renders"`  // Renders since startup
+	Recycled int64 `json:"recycled"` // Browsers replaced for render/memory limits
+	Crashes  int64 `json:"crashes"`  // Browsers replaced after a crash
+
+	Endpoints []EndpointStatus `json:"endpoints,omitempty"` // Remote Chrome endpoints
+}
+
+// EndpointStatus shows the health of a remote Chrome endpoint
+type EndpointStatus struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
 }
 
 // ConversionMetrics tracks conversion statistics
@@ -205,6 +383,18 @@ type PDFInfo struct {
 	PDFVersion string `json:"pdf_version,omitempty"`
 	Encrypted  bool   `json:"encrypted"`
 	FileSize   int64  `json:"file_size"`
+
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+}
+
+// EncryptionInfo describes the encryption of a PDF
+type EncryptionInfo struct {
+	Method   string `json:"method"`   // AES-256, AES-128, RC4-128, RC4-40
+	Revision int    `json:"revision"` // Security handler revision (R)
+
+	// print_low, print_high, modify_assembly, modify_forms,
+	// modify_annotations, modify_other, extract, extract_accessibility
+	Permissions map[string]bool `json:"permissions"`
 }
 
 // TemplateRequest for template-based PDF generation
@@ -213,6 +403,13 @@ type TemplateRequest struct {
 	CustomHTML string                 `json:"custom_html,omitempty"` // For custom template
 	Data       map[string]interface{} `json:"data"`                  // Template variables
 	Options    *PDFOptions            `json:"options,omitempty"`
+	EInvoice   *EInvoiceOptions       `json:"e_invoice,omitempty"` // invoice template only
+}
+
+// EInvoiceOptions embeds a Factur-X / ZUGFeRD invoice built from the
+// template data, producing a PDF/A-3b
+type EInvoiceOptions struct {
+	Profile string `json:"profile,omitempty"` // MINIMUM, BASIC, EN16931 (default)
 }
 
 // WebhookConfig for async processing callbacks
@@ -260,8 +457,10 @@ type StorageResult struct {
 
 // ManipulateRequest for PDF manipulation operations
 type ManipulateRequest struct {
-	Operation string             `json:"operation"` // split, extract, rotate, compress, info, remove, reorder, to_images
-	PDF       string             `json:"pdf"`       // Base64 encoded PDF
+	Operation string             `json:"operation"`          // split, extract, rotate, compress, info, remove, reorder, to_images, set_metadata, number_pages, to_pdfa, sign, inspect_signatures, form_fields, fill_form, decrypt, change_permissions, extract_text, ocr, redact, compare, overlay, underlay, nup, booklet, resize, crop, to_pdfx
+	PDF       string             `json:"pdf"`                // Base64 encoded PDF
+	PDFs      []string           `json:"pdfs,omitempty"`     // Further documents for number_pages, numbered after PDF; the document compared with, or laid over or under, PDF
+	Password  string             `json:"password,omitempty"` // User or owner password of an encrypted PDF, also used for PDFs
 	Options   *ManipulateOptions `json:"options,omitempty"`
 }
 
@@ -271,9 +470,13 @@ type ManipulateOptions struct {
 	SplitType string `json:"split_type,omitempty"` // all, range, every_n
 	EveryN    int    `json:"every_n,omitempty"`
 
-	// For extract, remove, rotate
+	// For extract, remove, rotate, extract_text, ocr, nup, booklet, resize,
+	// crop; target pages of overlay and underlay
 	Pages string `json:"pages,omitempty"` // "1-3,5,7-9"
 
+	// For extract_text
+	TextMode string `json:"text_mode,omitempty"` // plain (default), layout, json
+
 	// For rotate
 	Rotation int `json:"rotation,omitempty"` // 90, 180, 270
 
@@ -286,6 +489,62 @@ type ManipulateOptions struct {
 	// For to_images
 	ImageFormat string `json:"image_format,omitempty"` // jpeg, png
 	DPI         int    `json:"dpi,omitempty"`
+
+	// For set_metadata
+	Metadata *PDFMetadata `json:"metadata,omitempty"`
+
+	// For number_pages
+	Numbering *PageNumbering `json:"numbering,omitempty"`
+
+	// For to_pdfa
+	PDFA *PDFAOptions `json:"pdfa,omitempty"`
+
+	// For to_pdfx
+	PrintProfile *PrintProfile `json:"print_profile,omitempty"`
+
+	// For sign
+	Sign *SignatureOptions `json:"sign,omitempty"`
+
+	// For ocr
+	OCR *OCROptions `json:"ocr,omitempty"`
+
+	// For redact
+	Redact *RedactOptions `json:"redact,omitempty"`
+
+	// For compare
+	Compare *CompareOptions `json:"compare,omitempty"`
+
+	// For overlay and underlay
+	Layer *LayerOptions `json:"layer,omitempty"`
+
+	// For nup, booklet, resize and crop
+	Layout *LayoutOptions `json:"layout,omitempty"`
+
+	// For change_permissions; owner_password is required
+	Security *PDFSecurity `json:"security,omitempty"`
+
+	// For fill_form: field name to value (string, bool for checkboxes,
+	// list of strings for multi-select lists)
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Flatten bool                   `json:"flatten,omitempty"` // Merge the fields into the page content
+}
+
+// PageNumbering configures page numbers or Bates numbers for number_pages
+type PageNumbering struct {
+	Format    string  `json:"format,omitempty"`     // Label text with {n} and {total}, default "{n}"
+	Style     string  `json:"style,omitempty"`      // arabic (default), roman, roman_upper
+	Position  string  `json:"position,omitempty"`   // top_left, top_center, top_right, bottom_left, bottom_center (default), bottom_right
+	Start     int     `json:"start,omitempty"`      // Number of the first page, default 1
+	SkipFirst bool    `json:"skip_first,omitempty"` // Leave the first page unlabeled; it still counts
+	FontSize  float64 `json:"font_size,omitempty"`  // Default 10
+	Color     string  `json:"color,omitempty"`      // Hex color, default black
+
+	// Bates numbering: prefix + zero-padded counter + suffix, continuing
+	// across all documents of the request
+	Bates  bool   `json:"bates,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	Digits int    `json:"digits,omitempty"` // Zero padding, default 6
 }
 
 // ManipulateResult contains operation result
@@ -308,6 +567,173 @@ type ManipulateResult struct {
 	OriginalSize   int64 `json:"original_size,omitempty"`
 	CompressedSize int64 `json:"compressed_size,omitempty"`
 	SavingsPercent int   `json:"savings_percent,omitempty"`
+
+	// For number_pages: pass as start to continue the sequence in another request
+	NextNumber int `json:"next_number,omitempty"`
+
+	// For inspect_signatures
+	Signatures []SignatureInfo `json:"signatures,omitempty"`
+
+	// For form_fields
+	FormFields []FormField `json:"form_fields,omitempty"`
+
+	// For extract_text
+	Text []PageText `json:"text,omitempty"`
+
+	// For ocr
+	OCR []OCRPage `json:"ocr,omitempty"`
+
+	// For redact: pages with redactions
+	Redactions []RedactionPage `json:"redactions,omitempty"`
+
+	// For compare
+	Comparison *CompareResult `json:"comparison,omitempty"`
+}
+
+// LayerOptions maps the pages of the overlay or underlay PDF onto the
+// target pages
+type LayerOptions struct {
+	Source string `json:"source,omitempty"` // first (default), each, or a page range of the layer PDF applied in order, e.g. "2,1"
+	Repeat string `json:"repeat,omitempty"` // With a range: layer pages cycled once the range runs out
+}
+
+// LayoutOptions changes page geometry for nup, booklet, resize and crop
+type LayoutOptions struct {
+	// Sheet size for nup and booklet, target size for resize
+	PageSize         PageSize        `json:"page_size,omitempty"`         // A4, A3, Letter, Legal, Tabloid, Custom
+	CustomDimensions *PageDimensions `json:"custom_dimensions,omitempty"` // With Custom, in inches
+	Orientation      Orientation     `json:"orientation,omitempty"`       // Chosen to fit the pages when empty
+
+	// For nup
+	PerSheet int  `json:"per_sheet,omitempty"` // 2, 4 (default), 6 or 9
+	Border   bool `json:"border,omitempty"`    // Frame each page
+
+	// For crop: an explicit box, or margins trimmed from the current one
+	Rect    *[4]float64  `json:"rect,omitempty"`    // x, y, width, height in points in the page's coordinate space
+	Margins *CropMargins `json:"margins,omitempty"` // For the edges as displayed
+	Boxes   []string     `json:"boxes,omitempty"`   // crop (default), trim, bleed, art
+}
+
+// CropMargins are trimmed from a page box (in points, unlike Margins)
+type CropMargins struct {
+	Top    float64 `json:"top"`
+	Bottom float64 `json:"bottom"`
+	Left   float64 `json:"left"`
+	Right  float64 `json:"right"`
+}
+
+// CompareOptions controls how compare renders and judges pages
+type CompareOptions struct {
+	DPI       int     `json:"dpi,omitempty"`       // Rendering resolution, default 72, at most 600
+	Threshold float64 `json:"threshold,omitempty"` // Percentage of changed pixels a page may have and pass, default 0
+	Tolerance int     `json:"tolerance,omitempty"` // Channel difference (0-255) still counted as equal, default 0
+}
+
+// CompareResult is the outcome of comparing two PDFs
+type CompareResult struct {
+	Passed bool       `json:"passed"` // Same page count and every page within the threshold
+	PagesA int        `json:"pages_a"`
+	PagesB int        `json:"pages_b"`
+	Failed int        `json:"failed"` // Pages over the threshold
+	Pages  []PageDiff `json:"pages"`
+}
+
+// PageDiff reports the changes on one page
+type PageDiff struct {
+	Page     int      `json:"page"`
+	Score    float64  `json:"score"` // Percentage of changed pixels
+	Passed   bool     `json:"passed"`
+	Image    string   `json:"image,omitempty"`     // Base64 PNG with changed pixels in red, when any changed
+	TextDiff []string `json:"text_diff,omitempty"` // Removed ("- ") and added ("+ ") lines
+}
+
+// RedactOptions selects the content redact removes. Patterns and literals
+// are searched in the pages selected by pages, all pages by default.
+type RedactOptions struct {
+	Patterns   []string        `json:"patterns,omitempty"` // Regular expressions (RE2 syntax)
+	Literals   []string        `json:"literals,omitempty"` // Exact strings
+	IgnoreCase bool            `json:"ignore_case,omitempty"`
+	Areas      []RedactionArea `json:"areas,omitempty"`     // Explicit regions
+	Rasterize  bool            `json:"rasterize,omitempty"` // Replace redacted pages with images
+	DPI        int             `json:"dpi,omitempty"`       // Rasterization resolution, default 150
+}
+
+// RedactionArea is an explicit region to redact
+type RedactionArea struct {
+	Page int        `json:"page"`
+	Rect [4]float64 `json:"rect"` // x, y, width, height in points from the bottom-left corner of the visible page
+}
+
+// RedactionPage reports what was removed from one page
+type RedactionPage struct {
+	Page       int         `json:"page"`
+	Redactions []Redaction `json:"redactions"`
+	Rasterized bool        `json:"rasterized,omitempty"`
+}
+
+// Redaction is one removed region
+type Redaction struct {
+	Rect    [4]float64 `json:"rect"`              // x, y, width, height in points from the bottom-left corner
+	Text    string     `json:"text,omitempty"`    // Matched text
+	Pattern string     `json:"pattern,omitempty"` // Pattern or literal that matched; empty for areas
+}
+
+// PageText is the text of one page
+type PageText struct {
+	Page   int        `json:"page"`
+	Text   string     `json:"text"`
+	Width  float64    `json:"width,omitempty"`  // json mode, in points
+	Height float64    `json:"height,omitempty"` // json mode, in points
+	Words  []TextWord `json:"words,omitempty"`  // json mode
+}
+
+// TextWord is a word with its position on the page
+type TextWord struct {
+	Text  string     `json:"text"`
+	Rect  [4]float64 `json:"rect"`  // x, y, width, height in points from the bottom-left corner
+	Block int        `json:"block"` // Index of the text block on the page
+	Line  int        `json:"line"`  // Index of the line within the block
+}
+
+// FormField describes an AcroForm field
+type FormField struct {
+	Name     string       `json:"name"` // Fully qualified, parent names joined by dots
+	Type     string       `json:"type"` // text, checkbox, radio, choice, signature, button
+	Value    interface{}  `json:"value,omitempty"`
+	Default  interface{}  `json:"default,omitempty"`
+	Options  []string     `json:"options,omitempty"`  // Choice items, or checkbox/radio on-states
+	Multiple bool         `json:"multiple,omitempty"` // Choice list allowing several selections
+	ReadOnly bool         `json:"read_only,omitempty"`
+	Required bool         `json:"required,omitempty"`
+	Widgets  []FormWidget `json:"widgets"`
+}
+
+// FormWidget is the on-page appearance of a field
+type FormWidget struct {
+	Page  int        `json:"page"`
+	Rect  [4]float64 `json:"rect"`            // x, y, width, height in points from the bottom-left corner
+	State string     `json:"state,omitempty"` // On-state of a checkbox or radio button
+}
+
+// SignatureInfo describes one signature field and the result of verifying it
+type SignatureInfo struct {
+	Field     string `json:"field"`
+	Signed    bool   `json:"signed"`           // False for empty signature fields
+	Signer    string `json:"signer,omitempty"` // Certificate subject
+	Issuer    string `json:"issuer,omitempty"`
+	SubFilter string `json:"sub_filter,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Location  string `json:"location,omitempty"`
+
+	SigningTime *time.Time `json:"signing_time,omitempty"` // From a verified timestamp token when present, else as claimed by the signer
+	Timestamped bool       `json:"timestamped"`            // Has a verified RFC 3161 timestamp token
+
+	ByteRange      []int64 `json:"byte_range,omitempty"`
+	CoversDocument bool    `json:"covers_document"` // The signed ranges span the whole file
+
+	DigestValid bool   `json:"digest_valid"` // Signed bytes unchanged and the signature value verifies
+	Trusted     bool   `json:"trusted"`      // Certificate chains to the trust store
+	Error       string `json:"error,omitempty"`
 }
 
 // BatchRequest for processing multiple conversions
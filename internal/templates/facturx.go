@@ -0,0 +1,565 @@
+package templates
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Factur-X / ZUGFeRD profiles
+const (
+	FacturXMinimum  = "MINIMUM"
+	FacturXBasic    = "BASIC"
+	FacturXEN16931  = "EN16931"
+	FacturXFileName = "factur-x.xml"
+)
+
+// facturXGuidelines are the specification identifiers (BT-24) per profile
+var facturXGuidelines = map[string]string{
+	FacturXMinimum: "urn:factur-x.eu:1p0:minimum",
+	FacturXBasic:   "urn:cen.eu:en16931:2017#compliant#urn:factur-x.eu:1p0:basic",
+	FacturXEN16931: "urn:cen.eu:en16931:2017",
+}
+
+// currencyCodes maps the invoice template's currency symbols to ISO 4217
+var currencyCodes = map[string]string{
+	"$":  "USD",
+	"€":  "EUR",
+	"£":  "GBP",
+	"¥":  "JPY",
+	"₹":  "INR",
+	"Fr": "CHF",
+}
+
+// dateLayouts are the date formats accepted for invoice_date and due_date
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02.01.2006",
+	"01/02/2006",
+}
+
+// FacturX is a generated Cross-Industry-Invoice ready to embed
+type FacturX struct {
+	XML          []byte
+	Profile      string // Normalized profile name
+	Relationship string // AFRelationship of the attachment
+}
+
+// facturXLine is one invoice line with its resolved amounts
+type facturXLine struct {
+	description string
+	quantity    float64
+	unitPrice   float64
+	amount      float64
+	unit        string
+	taxRate     float64
+}
+
+// facturXTax is the VAT breakdown for one rate
+type facturXTax struct {
+	rate   float64
+	basis  float64
+	amount float64
+}
+
+// NormalizeFacturXProfile returns the canonical profile name; EN16931
+// (also called COMFORT) is the default
+func NormalizeFacturXProfile(profile string) (string, error) {
+	p := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(profile))
+	switch p {
+	case "":
+		return FacturXEN16931, nil
+	case "COMFORT":
+		return FacturXEN16931, nil
+	case FacturXMinimum, FacturXBasic, FacturXEN16931:
+		return p, nil
+	}
+	return "", fmt.Errorf("unsupported Factur-X profile %q (use MINIMUM, BASIC or EN16931)", profile)
+}
+
+// BuildFacturX builds the CII XML for the invoice template's data. Totals are
+// recomputed from the items and must agree with subtotal, tax and total.
+func BuildFacturX(data map[string]interface{}, profile string) (*FacturX, error) {
+	profile, err := NormalizeFacturXProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	full := profile != FacturXMinimum
+
+	for _, key := range []string{"invoice_number", "company_name", "company_country", "client_name"} {
+		if dataString(data, key) == "" {
+			return nil, fmt.Errorf("e-invoice requires %s", key)
+		}
+	}
+	if full {
+		if dataString(data, "client_country") == "" {
+			return nil, fmt.Errorf("e-invoice requires client_country for profile %s", profile)
+		}
+		if dataString(data, "company_vat_id") == "" {
+			return nil, fmt.Errorf("e-invoice requires company_vat_id for profile %s", profile)
+		}
+	}
+
+	currency := currencyCode(data)
+	issued, ok := dataDate(data, "invoice_date")
+	if !ok {
+		if dataString(data, "invoice_date") != "" {
+			return nil, fmt.Errorf("e-invoice: unrecognized invoice_date %q (use YYYY-MM-DD)", dataString(data, "invoice_date"))
+		}
+		issued = time.Now()
+	}
+
+	docRate, _ := dataNumber(data["tax_rate"])
+	lines, err := invoiceLines(data, docRate)
+	if err != nil {
+		return nil, err
+	}
+	if full && len(lines) == 0 {
+		return nil, fmt.Errorf("e-invoice requires items for profile %s", profile)
+	}
+
+	discount, _ := dataNumber(data["discount"])
+	discount = round2(discount)
+	paid, _ := dataNumber(data["amount_paid"])
+	paid = round2(paid)
+
+	var lineTotal, taxBasis, taxTotal, grandTotal float64
+	var taxes []facturXTax
+	if len(lines) > 0 {
+		for _, l := range lines {
+			lineTotal += l.amount
+		}
+		lineTotal = round2(lineTotal)
+		taxBasis = round2(lineTotal - discount)
+		taxes = taxBreakdown(lines, discount, docRate)
+		for _, t := range taxes {
+			taxTotal += t.amount
+		}
+		taxTotal = round2(taxTotal)
+		grandTotal = round2(taxBasis + taxTotal)
+
+		// The XML must describe the same invoice as the visual PDF
+		for _, check := range []struct {
+			key       string
+			computed  float64
+			tolerance float64
+		}{
+			{"subtotal", lineTotal, 0.005},
+			{"tax", taxTotal, 0.01 * float64(len(taxes))},
+			{"total", grandTotal, 0.01 * float64(len(taxes))},
+		} {
+			if v, ok := dataNumber(data[check.key]); ok && math.Abs(v-check.computed) > check.tolerance {
+				return nil, fmt.Errorf("e-invoice: %s is %.2f but the items add up to %.2f", check.key, v, check.computed)
+			}
+		}
+	} else {
+		// MINIMUM carries document totals only
+		var ok bool
+		if grandTotal, ok = dataNumber(data["total"]); !ok {
+			return nil, fmt.Errorf("e-invoice requires items or total")
+		}
+		taxTotal, _ = dataNumber(data["tax"])
+		grandTotal, taxTotal = round2(grandTotal), round2(taxTotal)
+		taxBasis = round2(grandTotal - taxTotal)
+	}
+
+	x := &xmlWriter{}
+	x.raw(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	x.open(`rsm:CrossIndustryInvoice xmlns:rsm="urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"` +
+		` xmlns:qdt="urn:un:unece:uncefact:data:standard:QualifiedDataType:100"` +
+		` xmlns:ram="urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100"` +
+		` xmlns:udt="urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100"`)
+
+	x.open("rsm:ExchangedDocumentContext")
+	x.open("ram:GuidelineSpecifiedDocumentContextParameter")
+	x.elem("ram:ID", facturXGuidelines[profile])
+	x.close("ram:GuidelineSpecifiedDocumentContextParameter")
+	x.close("rsm:ExchangedDocumentContext")
+
+	x.open("rsm:ExchangedDocument")
+	x.elem("ram:ID", dataString(data, "invoice_number"))
+	x.elem("ram:TypeCode", "380") // Commercial invoice
+	x.date("ram:IssueDateTime", issued)
+	if notes := dataString(data, "notes"); full && notes != "" {
+		x.open("ram:IncludedNote")
+		x.elem("ram:Content", notes)
+		x.close("ram:IncludedNote")
+	}
+	x.close("rsm:ExchangedDocument")
+
+	x.open("rsm:SupplyChainTradeTransaction")
+
+	if full {
+		for i, l := range lines {
+			x.open("ram:IncludedSupplyChainTradeLineItem")
+			x.open("ram:AssociatedDocumentLineDocument")
+			x.elem("ram:LineID", strconv.Itoa(i+1))
+			x.close("ram:AssociatedDocumentLineDocument")
+			x.open("ram:SpecifiedTradeProduct")
+			x.elem("ram:Name", l.description)
+			x.close("ram:SpecifiedTradeProduct")
+			x.open("ram:SpecifiedLineTradeAgreement")
+			x.open("ram:NetPriceProductTradePrice")
+			x.elem("ram:ChargeAmount", decimal(l.unitPrice))
+			x.close("ram:NetPriceProductTradePrice")
+			x.close("ram:SpecifiedLineTradeAgreement")
+			x.open("ram:SpecifiedLineTradeDelivery")
+			x.elemAttr("ram:BilledQuantity", "unitCode", l.unit, decimal(l.quantity))
+			x.close("ram:SpecifiedLineTradeDelivery")
+			x.open("ram:SpecifiedLineTradeSettlement")
+			x.open("ram:ApplicableTradeTax")
+			x.elem("ram:TypeCode", "VAT")
+			x.elem("ram:CategoryCode", taxCategory(l.taxRate))
+			x.elem("ram:RateApplicablePercent", decimal(l.taxRate))
+			x.close("ram:ApplicableTradeTax")
+			x.open("ram:SpecifiedTradeSettlementLineMonetarySummation")
+			x.elem("ram:LineTotalAmount", money(l.amount))
+			x.close("ram:SpecifiedTradeSettlementLineMonetarySummation")
+			x.close("ram:SpecifiedLineTradeSettlement")
+			x.close("ram:IncludedSupplyChainTradeLineItem")
+		}
+	}
+
+	x.open("ram:ApplicableHeaderTradeAgreement")
+	if ref := dataString(data, "buyer_reference"); ref != "" {
+		x.elem("ram:BuyerReference", ref)
+	}
+	x.party("ram:SellerTradeParty", data, "company", full)
+	x.party("ram:BuyerTradeParty", data, "client", full)
+	if po := dataString(data, "purchase_order"); po != "" {
+		x.open("ram:BuyerOrderReferencedDocument")
+		x.elem("ram:IssuerAssignedID", po)
+		x.close("ram:BuyerOrderReferencedDocument")
+	}
+	x.close("ram:ApplicableHeaderTradeAgreement")
+
+	x.raw("<ram:ApplicableHeaderTradeDelivery/>\n")
+
+	x.open("ram:ApplicableHeaderTradeSettlement")
+	if ref := dataString(data, "payment_reference"); full && ref != "" {
+		x.elem("ram:PaymentReference", ref)
+	}
+	x.elem("ram:InvoiceCurrencyCode", currency)
+	if full {
+		if iban := dataString(data, "iban"); iban != "" {
+			x.open("ram:SpecifiedTradeSettlementPaymentMeans")
+			x.elem("ram:TypeCode", "58") // SEPA credit transfer
+			x.open("ram:PayeePartyCreditorFinancialAccount")
+			x.elem("ram:IBANID", strings.ReplaceAll(iban, " ", ""))
+			x.close("ram:PayeePartyCreditorFinancialAccount")
+			x.close("ram:SpecifiedTradeSettlementPaymentMeans")
+		}
+		for _, t := range taxes {
+			x.open("ram:ApplicableTradeTax")
+			x.elem("ram:CalculatedAmount", money(t.amount))
+			x.elem("ram:TypeCode", "VAT")
+			x.elem("ram:BasisAmount", money(t.basis))
+			x.elem("ram:CategoryCode", taxCategory(t.rate))
+			x.elem("ram:RateApplicablePercent", decimal(t.rate))
+			x.close("ram:ApplicableTradeTax")
+		}
+		if discount > 0 {
+			rate := discountRate(lines, docRate)
+			x.open("ram:SpecifiedTradeAllowanceCharge")
+			x.open("ram:ChargeIndicator")
+			x.elem("udt:Indicator", "false")
+			x.close("ram:ChargeIndicator")
+			x.elem("ram:ActualAmount", money(discount))
+			x.elem("ram:Reason", "Discount")
+			x.open("ram:CategoryTradeTax")
+			x.elem("ram:TypeCode", "VAT")
+			x.elem("ram:CategoryCode", taxCategory(rate))
+			x.elem("ram:RateApplicablePercent", decimal(rate))
+			x.close("ram:CategoryTradeTax")
+			x.close("ram:SpecifiedTradeAllowanceCharge")
+		}
+
+		due, hasDue := dataDate(data, "due_date")
+		terms := dataString(data, "payment_terms")
+		if terms == "" && !hasDue && dataString(data, "due_date") != "" {
+			terms = "Due " + dataString(data, "due_date")
+		}
+		if hasDue || terms != "" {
+			x.open("ram:SpecifiedTradePaymentTerms")
+			if terms != "" {
+				x.elem("ram:Description", terms)
+			}
+			if hasDue {
+				x.date("ram:DueDateDateTime", due)
+			}
+			x.close("ram:SpecifiedTradePaymentTerms")
+		}
+	}
+
+	x.open("ram:SpecifiedTradeSettlementHeaderMonetarySummation")
+	if full {
+		x.elem("ram:LineTotalAmount", money(lineTotal))
+		if discount > 0 {
+			x.elem("ram:AllowanceTotalAmount", money(discount))
+		}
+	}
+	x.elem("ram:TaxBasisTotalAmount", money(taxBasis))
+	x.elemAttr("ram:TaxTotalAmount", "currencyID", currency, money(taxTotal))
+	x.elem("ram:GrandTotalAmount", money(grandTotal))
+	if full && paid > 0 {
+		x.elem("ram:TotalPrepaidAmount", money(paid))
+	}
+	x.elem("ram:DuePayableAmount", money(round2(grandTotal-paid)))
+	x.close("ram:SpecifiedTradeSettlementHeaderMonetarySummation")
+	x.close("ram:ApplicableHeaderTradeSettlement")
+
+	x.close("rsm:SupplyChainTradeTransaction")
+	x.close("rsm:CrossIndustryInvoice")
+
+	relationship := "Alternative"
+	if profile == FacturXMinimum {
+		// MINIMUM isn't a full invoice, so it only supplements the PDF
+		relationship = "Data"
+	}
+	return &FacturX{XML: []byte(x.b.String()), Profile: profile, Relationship: relationship}, nil
+}
+
+// invoiceLines reads the items array of the invoice data
+func invoiceLines(data map[string]interface{}, docRate float64) ([]facturXLine, error) {
+	items, _ := data["items"].([]interface{})
+	lines := make([]facturXLine, 0, len(items))
+	for i, it := range items {
+		item, ok := it.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("e-invoice: item %d is not an object", i+1)
+		}
+		l := facturXLine{
+			description: dataString(item, "description"),
+			unit:        dataString(item, "unit"),
+			taxRate:     docRate,
+		}
+		if l.description == "" {
+			return nil, fmt.Errorf("e-invoice: item %d has no description", i+1)
+		}
+		if l.unit == "" {
+			l.unit = "C62" // UN/ECE "one"
+		}
+		var hasQty, hasPrice, hasAmount bool
+		l.quantity, hasQty = dataNumber(item["quantity"])
+		l.unitPrice, hasPrice = dataNumber(item["unit_price"])
+		l.amount, hasAmount = dataNumber(item["amount"])
+		if !hasQty {
+			l.quantity = 1
+		}
+		switch {
+		case !hasAmount && !hasPrice:
+			return nil, fmt.Errorf("e-invoice: item %d needs unit_price or amount", i+1)
+		case !hasAmount:
+			l.amount = l.quantity * l.unitPrice
+		case !hasPrice:
+			l.unitPrice = l.amount
+			if l.quantity != 0 {
+				l.unitPrice = l.amount / l.quantity
+			}
+		}
+		l.amount = round2(l.amount)
+		if rate, ok := dataNumber(item["tax_rate"]); ok {
+			l.taxRate = rate
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// taxBreakdown groups the lines by VAT rate; a document discount reduces
+// the basis of the rate it's charged at
+func taxBreakdown(lines []facturXLine, discount, docRate float64) []facturXTax {
+	basis := map[float64]float64{}
+	for _, l := range lines {
+		basis[l.taxRate] += l.amount
+	}
+	if discount > 0 {
+		basis[discountRate(lines, docRate)] -= discount
+	}
+
+	rates := make([]float64, 0, len(basis))
+	for r := range basis {
+		rates = append(rates, r)
+	}
+	sort.Float64s(rates)
+
+	taxes := make([]facturXTax, 0, len(rates))
+	for _, r := range rates {
+		b := round2(basis[r])
+		taxes = append(taxes, facturXTax{rate: r, basis: b, amount: round2(b * r / 100)})
+	}
+	return taxes
+}
+
+// discountRate is the VAT rate a document discount is charged at: the
+// document tax_rate if any line uses it, else the first line's rate
+func discountRate(lines []facturXLine, docRate float64) float64 {
+	for _, l := range lines {
+		if l.taxRate == docRate {
+			return docRate
+		}
+	}
+	if len(lines) > 0 {
+		return lines[0].taxRate
+	}
+	return docRate
+}
+
+// taxCategory is the UNCL5305 code: standard rate, or zero rated
+func taxCategory(rate float64) string {
+	if rate > 0 {
+		return "S"
+	}
+	return "Z"
+}
+
+// currencyCode resolves the ISO 4217 code from currency_code or the
+// currency symbol the template displays (default $)
+func currencyCode(data map[string]interface{}) string {
+	if code := dataString(data, "currency_code"); code != "" {
+		return strings.ToUpper(code)
+	}
+	symbol := strings.TrimSpace(dataString(data, "currency"))
+	if symbol == "" {
+		symbol = "$"
+	}
+	if code, ok := currencyCodes[symbol]; ok {
+		return code
+	}
+	if len(symbol) == 3 {
+		return strings.ToUpper(symbol)
+	}
+	return "USD"
+}
+
+func dataString(data map[string]interface{}, key string) string {
+	switch v := data[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// dataNumber reads a JSON number, or a string holding one
+func dataNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func dataDate(data map[string]interface{}, key string) (time.Time, bool) {
+	s := dataString(data, key)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func decimal(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// xmlWriter writes indented XML without a schema-driven encoder, keeping
+// element order under our control as the CII schema requires
+type xmlWriter struct {
+	b     strings.Builder
+	depth int
+}
+
+func (x *xmlWriter) raw(s string) {
+	x.b.WriteString(strings.Repeat("  ", x.depth))
+	x.b.WriteString(s)
+}
+
+func (x *xmlWriter) open(tag string) {
+	x.raw("<" + tag + ">\n")
+	x.depth++
+}
+
+func (x *xmlWriter) close(tag string) {
+	x.depth--
+	x.raw("</" + tag + ">\n")
+}
+
+func (x *xmlWriter) elem(tag, value string) {
+	x.raw("<" + tag + ">" + xmlEscape(value) + "</" + tag + ">\n")
+}
+
+func (x *xmlWriter) elemAttr(tag, attr, attrValue, value string) {
+	x.raw(fmt.Sprintf("<%s %s=\"%s\">%s</%s>\n", tag, attr, xmlEscape(attrValue), xmlEscape(value), tag))
+}
+
+func (x *xmlWriter) date(tag string, t time.Time) {
+	x.open(tag)
+	x.elemAttr("udt:DateTimeString", "format", "102", t.Format("20060102"))
+	x.close(tag)
+}
+
+// party writes a seller or buyer from the <prefix>_name, _address,
+// _postcode, _city, _country and _vat_id keys. MINIMUM has only the
+// seller's country and VAT ID besides the names.
+func (x *xmlWriter) party(tag string, data map[string]interface{}, prefix string, full bool) {
+	seller := prefix == "company"
+	x.open(tag)
+	x.elem("ram:Name", dataString(data, prefix+"_name"))
+	if country := dataString(data, prefix+"_country"); country != "" && (full || seller) {
+		x.open("ram:PostalTradeAddress")
+		if full {
+			if v := dataString(data, prefix+"_postcode"); v != "" {
+				x.elem("ram:PostcodeCode", v)
+			}
+			if v := dataString(data, prefix+"_address"); v != "" {
+				x.elem("ram:LineOne", v)
+			}
+			if v := dataString(data, prefix+"_city"); v != "" {
+				x.elem("ram:CityName", v)
+			}
+		}
+		x.elem("ram:CountryID", strings.ToUpper(country))
+		x.close("ram:PostalTradeAddress")
+	}
+	if vat := dataString(data, prefix+"_vat_id"); vat != "" && (full || seller) {
+		x.open("ram:SpecifiedTaxRegistration")
+		x.elemAttr("ram:ID", "schemeID", "VA", vat)
+		x.close("ram:SpecifiedTaxRegistration")
+	}
+	x.close(tag)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
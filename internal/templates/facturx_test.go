@@ -0,0 +1,154 @@
+package templates
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// testInvoice returns invoice data whose totals match its items: 2 x 50.00
+// and 1 x 20.00 at 20% VAT
+func testInvoice() map[string]interface{} {
+	return map[string]interface{}{
+		"invoice_number":    "INV-001",
+		"invoice_date":      "2024-03-01",
+		"due_date":          "2024-03-31",
+		"currency":          "€",
+		"company_name":      "Seller GmbH",
+		"company_country":   "de",
+		"company_vat_id":    "DE123456789",
+		"client_name":       "Buyer SARL",
+		"client_country":    "fr",
+		"tax_rate":          20.0,
+		"payment_reference": "INV-001",
+		"items": []interface{}{
+			map[string]interface{}{"description": "Consulting", "quantity": 2.0, "unit_price": 50.0},
+			map[string]interface{}{"description": "Travel", "amount": 20.0},
+		},
+		"subtotal": 120.0,
+		"tax":      24.0,
+		"total":    144.0,
+	}
+}
+
+func TestNormalizeFacturXProfile(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    string
+		wantErr bool
+	}{
+		{"", FacturXEN16931, false},
+		{"comfort", FacturXEN16931, false},
+		{"EN 16931", FacturXEN16931, false},
+		{"basic", FacturXBasic, false},
+		{"Minimum", FacturXMinimum, false},
+		{"EXTENDED", "", true},
+		{"XRECHNUNG", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			got, err := NormalizeFacturXProfile(tt.profile)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("NormalizeFacturXProfile(%q) = %q, %v; want %q, error %v", tt.profile, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildFacturXRejectsBadInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		change  func(map[string]interface{})
+		wantErr string
+	}{
+		{"unknown profile", "EXTENDED", func(map[string]interface{}) {}, "unsupported Factur-X profile"},
+		{"mismatched subtotal", "", func(d map[string]interface{}) { d["subtotal"] = 110.0 }, "subtotal is 110.00 but the items add up to 120.00"},
+		{"mismatched tax", "", func(d map[string]interface{}) { d["tax"] = 20.0 }, "tax is 20.00"},
+		{"mismatched total", "BASIC", func(d map[string]interface{}) { d["total"] = 150.0 }, "total is 150.00"},
+		{"missing VAT ID", "BASIC", func(d map[string]interface{}) { delete(d, "company_vat_id") }, "company_vat_id"},
+		{"no items", "EN16931", func(d map[string]interface{}) { delete(d, "items") }, "requires items"},
+		{"no items or total", "MINIMUM", func(d map[string]interface{}) { delete(d, "items"); delete(d, "total") }, "requires items or total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testInvoice()
+			tt.change(data)
+			_, err := BuildFacturX(data, tt.profile)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("BuildFacturX() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildFacturXProfiles(t *testing.T) {
+	tests := []struct {
+		profile      string
+		guideline    string
+		relationship string
+		lineItems    bool
+	}{
+		{FacturXMinimum, "urn:factur-x.eu:1p0:minimum", "Data", false},
+		{FacturXBasic, "urn:cen.eu:en16931:2017#compliant#urn:factur-x.eu:1p0:basic", "Alternative", true},
+		{FacturXEN16931, "urn:cen.eu:en16931:2017", "Alternative", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			fx, err := BuildFacturX(testInvoice(), tt.profile)
+			if err != nil {
+				t.Fatalf("BuildFacturX() error = %v", err)
+			}
+			doc := string(fx.XML)
+			if err := xml.Unmarshal(fx.XML, new(struct{})); err != nil {
+				t.Fatalf("generated XML is not well-formed: %v", err)
+			}
+			if fx.Profile != tt.profile || fx.Relationship != tt.relationship {
+				t.Errorf("Profile, Relationship = %q, %q; want %q, %q", fx.Profile, fx.Relationship, tt.profile, tt.relationship)
+			}
+			if !strings.Contains(doc, "<ram:ID>"+tt.guideline+"</ram:ID>") {
+				t.Errorf("guideline %q missing", tt.guideline)
+			}
+			if got := strings.Contains(doc, "<ram:IncludedSupplyChainTradeLineItem>"); got != tt.lineItems {
+				t.Errorf("line items present = %v, want %v", got, tt.lineItems)
+			}
+			if !strings.Contains(doc, "<ram:GrandTotalAmount>144.00</ram:GrandTotalAmount>") {
+				t.Errorf("grand total missing:\n%s", doc)
+			}
+		})
+	}
+}
+
+func TestBuildFacturXElementOrder(t *testing.T) {
+	fx, err := BuildFacturX(testInvoice(), FacturXEN16931)
+	if err != nil {
+		t.Fatalf("BuildFacturX() error = %v", err)
+	}
+	doc := string(fx.XML)
+
+	// CII is validated against an XSD with fixed sequences
+	sequences := [][]string{
+		{"<rsm:ExchangedDocumentContext>", "<rsm:ExchangedDocument>", "<rsm:SupplyChainTradeTransaction>"},
+		{"<ram:IncludedSupplyChainTradeLineItem>", "<ram:ApplicableHeaderTradeAgreement>",
+			"<ram:ApplicableHeaderTradeDelivery/>", "<ram:ApplicableHeaderTradeSettlement>"},
+		{"<ram:SellerTradeParty>", "<ram:BuyerTradeParty>"},
+		{"<ram:PaymentReference>", "<ram:InvoiceCurrencyCode>", "<ram:CalculatedAmount>",
+			"<ram:SpecifiedTradePaymentTerms>", "<ram:SpecifiedTradeSettlementHeaderMonetarySummation>"},
+		{"<ram:LineTotalAmount>120.00", "<ram:TaxBasisTotalAmount>", "<ram:TaxTotalAmount currencyID=\"EUR\">",
+			"<ram:GrandTotalAmount>", "<ram:DuePayableAmount>"},
+	}
+	for _, seq := range sequences {
+		last := -1
+		for _, tag := range seq {
+			i := strings.Index(doc, tag)
+			if i < 0 {
+				t.Errorf("%s missing", tag)
+				break
+			}
+			if i < last {
+				t.Errorf("%s out of order in %v", tag, seq)
+			}
+			last = i
+		}
+	}
+}